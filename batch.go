@@ -0,0 +1,191 @@
+package toml2x
+
+import (
+    "context"
+    "errors"
+    "io"
+    "io/ioutil"
+    "os"
+    "runtime"
+    "sync"
+
+    "github.com/whencome/toml2x/redact"
+    "github.com/whencome/toml2x/schema"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Input 批量转换的一个输入，Reader为空时从Name指定的文件中读取
+type Input struct {
+    Name   string    // 文件路径或者输入的名称，用于错误信息以及位置信息
+    Reader io.Reader // 输入内容
+}
+
+// Result 批量转换的结果，与输入一一对应
+type Result struct {
+    Name   string
+    Output string
+    Err    error
+}
+
+// BatchOptions 批量转换选项
+type BatchOptions struct {
+    Format   string // 输出格式：json，xml，php，toml，yaml，schema
+    DataType string // 配置的数据类型：single，table，默认为table
+    Workers  int    // 并发数，默认为CPU核数
+    Options         // 转换选项
+}
+
+// Convert 转换为指定的格式
+// format 输出格式：json，xml，php，toml，yaml，schema（根据配置推断的json schema）
+// dataType 配置的数据类型，single，table
+// toml toml配置内容
+func Convert(format string, dataType string, toml string) (string, error) {
+    return ConvertWithOptions(format, dataType, toml, nil)
+}
+
+// ConvertWithOptions 使用指定的选项转换为指定的格式
+func ConvertWithOptions(format string, dataType string, toml string, opts *Options) (string, error) {
+    if !IsFormat(format) {
+        return "", errors.New("unsupported format: " + format)
+    }
+    obj, err := parseWithOptions(dataType, toml, opts)
+    if err != nil {
+        return "", err
+    }
+    if opts != nil && opts.Profile != "" && dataType != "single" {
+        if obj, err = ApplyProfile(obj, opts.Profile, opts.ProfileKeys); err != nil {
+            return "", err
+        }
+    }
+    if opts != nil && opts.Schema != nil {
+        obj = opts.Schema.Apply(obj)
+        if violations := opts.Schema.ValidateRedacted(obj, opts.secret()); len(violations) > 0 {
+            return "", &schema.ValidationError{Violations: violations}
+        }
+    }
+    if opts != nil && opts.Redact != nil {
+        obj = redact.Redact(obj, opts.redactOptions())
+    }
+    if format == "schema" && opts != nil {
+        return schema.Infer(obj, opts.Infer).Json(), nil
+    }
+    return Format(format, obj)
+}
+
+// Format 将解析后的对象转换为指定格式
+func Format(format string, obj *xtype.Object) (string, error) {
+    switch format {
+    case "schema":
+        return schema.Infer(obj, nil).Json(), nil
+    case "json":
+        return obj.Json(true), nil
+    case "xml":
+        return obj.Xml(), nil
+    case "php":
+        return obj.Php(), nil
+    case "toml":
+        return obj.Toml(false), nil
+    case "yaml":
+        return obj.Yaml(), nil
+    }
+    return "", errors.New("unsupported format: " + format)
+}
+
+// IsFormat 判断是否是支持的输出格式
+func IsFormat(format string) bool {
+    switch format {
+    case "json", "xml", "php", "toml", "yaml", "schema":
+        return true
+    }
+    return false
+}
+
+// ConvertBatch 使用固定数量的goroutine并发转换多个输入，结果按输入的顺序返回
+// ctx 取消后不再开始新的转换，未完成的输入的错误为ctx.Err()，存在未完成的输入时同时返回ctx.Err()
+func ConvertBatch(ctx context.Context, inputs []Input, opts BatchOptions) ([]Result, error) {
+    if !IsFormat(opts.Format) {
+        return nil, errors.New("unsupported format: " + opts.Format)
+    }
+    if opts.DataType == "" {
+        opts.DataType = "table"
+    }
+    workers := opts.Workers
+    if workers <= 0 {
+        workers = runtime.NumCPU()
+    }
+    if workers > len(inputs) {
+        workers = len(inputs)
+    }
+
+    results := make([]Result, len(inputs))
+    jobs := make(chan int)
+    wg := sync.WaitGroup{}
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range jobs {
+                results[i] = convertInput(ctx, inputs[i], opts)
+            }
+        }()
+    }
+
+    next := 0
+dispatch:
+    for ; next < len(inputs); next++ {
+        select {
+        case jobs <- next:
+        case <-ctx.Done():
+            break dispatch
+        }
+    }
+    close(jobs)
+    wg.Wait()
+
+    err := ctx.Err()
+    if err == nil {
+        return results, nil
+    }
+    for i := next; i < len(inputs); i++ {
+        results[i] = Result{Name: inputs[i].Name, Err: err}
+    }
+    // 取消之前所有的输入都已经转换完成时不返回错误
+    for _, rs := range results {
+        if rs.Err == err {
+            return results, err
+        }
+    }
+    return results, nil
+}
+
+// convertInput 转换单个输入，每个输入使用独立的解析结果，不与其他goroutine共享
+func convertInput(ctx context.Context, in Input, opts BatchOptions) Result {
+    rs := Result{Name: in.Name}
+    if err := ctx.Err(); err != nil {
+        rs.Err = err
+        return rs
+    }
+    r := in.Reader
+    if r == nil {
+        f, err := os.Open(in.Name)
+        if err != nil {
+            rs.Err = err
+            return rs
+        }
+        defer f.Close()
+        r = f
+    }
+    // 超出文档大小限制的部分不需要读取
+    if l := opts.Limits; l != nil && l.MaxDocumentSize > 0 {
+        r = io.LimitReader(r, int64(l.MaxDocumentSize)+1)
+    }
+    content, err := ioutil.ReadAll(r)
+    if err != nil {
+        rs.Err = err
+        return rs
+    }
+    copts := opts.Options
+    copts.File = in.Name
+    rs.Output, rs.Err = ConvertWithOptions(opts.Format, opts.DataType, string(content), &copts)
+    return rs
+}
@@ -0,0 +1,101 @@
+package toml2x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConvertBatch(t *testing.T) {
+	example, err := ioutil.ReadFile("example.toml")
+	if err != nil {
+		t.Fatalf("read file content failed: %s\n", err)
+	}
+	inputs := make([]Input, 0)
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("conf_%d.toml", i)
+		inputs = append(inputs, Input{Name: name, Reader: strings.NewReader(fmt.Sprintf("name = %q\nindex = %d\n", name, i))})
+	}
+	inputs = append(inputs, Input{Name: "example.toml"})
+	inputs = append(inputs, Input{Name: "bad.toml", Reader: strings.NewReader("ok = 1\nbad = \n")})
+	inputs = append(inputs, Input{Name: "missing.toml"})
+
+	results, err := ConvertBatch(context.Background(), inputs, BatchOptions{Format: "json", Workers: 4})
+	if err != nil {
+		t.Fatalf("convert batch failed: %s\n", err)
+	}
+	if len(results) != len(inputs) {
+		t.Fatalf("expected %d results, got %d\n", len(inputs), len(results))
+	}
+	for i := 0; i < 40; i++ {
+		expected := fmt.Sprintf(`{"name":"conf_%d.toml","index":%d}`, i, i)
+		if results[i].Name != inputs[i].Name || results[i].Err != nil || results[i].Output != expected {
+			t.Logf("result %d: expected %s, got %s %v\n", i, expected, results[i].Output, results[i].Err)
+			t.Fail()
+		}
+	}
+	if expected, _ := Json("table", string(example)); results[40].Output != expected {
+		t.Logf("result of example.toml does not match Json: %v\n", results[40].Err)
+		t.Fail()
+	}
+	if results[41].Err == nil || !strings.HasPrefix(results[41].Err.Error(), "bad.toml:2:7: ") {
+		t.Logf("expected positioned error for bad.toml, got %v\n", results[41].Err)
+		t.Fail()
+	}
+	if results[42].Err == nil {
+		t.Log("expected error for missing.toml\n")
+		t.Fail()
+	}
+}
+
+func TestConvertBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	inputs := []Input{
+		{Name: "a.toml", Reader: strings.NewReader("a = 1")},
+		{Name: "b.toml", Reader: strings.NewReader("b = 2")},
+	}
+	results, err := ConvertBatch(ctx, inputs, BatchOptions{Format: "xml"})
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v\n", err)
+		t.Fail()
+	}
+	for i, rs := range results {
+		if rs.Name != inputs[i].Name || !errors.Is(rs.Err, context.Canceled) {
+			t.Logf("result %d: expected canceled, got %+v\n", i, rs)
+			t.Fail()
+		}
+	}
+
+	// 所有输入完成之后才取消时不返回错误
+	ctx, cancel = context.WithCancel(context.Background())
+	inputs[1].Reader = &cancelReader{Reader: strings.NewReader("b = 2"), cancel: cancel}
+	results, err = ConvertBatch(ctx, inputs, BatchOptions{Format: "json", Workers: 1})
+	if err != nil || results[1].Err != nil || results[1].Output != `{"b":2}` {
+		t.Logf("expected no error when every input finished, got %v %+v\n", err, results)
+		t.Fail()
+	}
+
+	if _, err := ConvertBatch(context.Background(), inputs, BatchOptions{Format: "yml"}); err == nil {
+		t.Log("expected error for unsupported format\n")
+		t.Fail()
+	}
+}
+
+// cancelReader 读取完成时取消ctx
+type cancelReader struct {
+	io.Reader
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.cancel()
+	}
+	return n, err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "strings"
+
+    "github.com/whencome/toml2x"
+    "github.com/whencome/toml2x/crypt"
+    "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/redact"
+    "github.com/whencome/toml2x/schema"
+)
+
+// convert 转换命令
+func (e *env) convert(args []string) int {
+    fs := e.flagSet("convert", "toml2x convert [--to json|xml|php|toml|yaml|schema [--enums]] [--in file] [--out file] [--single|--table] [--env [--coerce]] [--include] [--include-root dir] [--profile name] [--schema file] [--redact] [--redact-patterns list] [--redact-hash] [--redact-hash-key file] [--key-file file]")
+    to := fs.String("to", "json", "output format: json, xml, php, toml, yaml or schema (a json schema inferred from the input)")
+    enums := fs.Bool("enums", false, "with --to schema, list the values of strings and integers as enums")
+    in := fs.String("in", "", "input file, defaults to stdin")
+    out := fs.String("out", "", "output file, defaults to stdout")
+    single := fs.Bool("single", false, "the input is a single value")
+    table := fs.Bool("table", false, "the input is a document of key/value pairs (default)")
+    expand := fs.Bool("env", false, "expand ${VAR} and ${VAR:-default} in strings and unquoted values from the environment")
+    coerce := fs.Bool("coerce", false, "with --env, parse expanded unquoted values as toml values instead of strings")
+    profile := fs.String("profile", "", "overlay [profile.<name>] and [env.<name>] on the base keys")
+    includeOptions := includeFlags(fs, "resolve include = [\"file.toml\"] relative to the input file")
+    schemaFile := fs.String("schema", "", "fill in defaults and coerce values with a schema, then check the result against it")
+    redactOptions := redactFlags(fs)
+    keyFile := keyFileFlag(fs)
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() > 0 {
+        return e.usageError(fs, "unexpected argument %q", fs.Arg(0))
+    }
+    if *single && *table {
+        return e.usageError(fs, "--single and --table can not be used together")
+    }
+    if *coerce && !*expand {
+        return e.usageError(fs, "--coerce requires --env")
+    }
+    if !toml2x.IsFormat(*to) {
+        return e.usageError(fs, "unsupported format %q", *to)
+    }
+    if *enums && *to != "schema" {
+        return e.usageError(fs, "--enums requires --to schema")
+    }
+    dataType := "table"
+    if *single {
+        dataType = "single"
+    }
+
+    sch, err := readSchema(*schemaFile)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    redactOpts, err := redactOptions()
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    content, name, err := e.readInput(*in)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    opts := &toml2x.Options{File: name, Profile: *profile, Schema: sch, Redact: redactOpts}
+    if *keyFile != "" {
+        if opts.Keys, err = crypt.NewKeyFile(*keyFile); err != nil {
+            return e.fail(fs.Name(), err)
+        }
+    }
+    if *expand {
+        opts.Interpolation = &parser.Interpolation{Coerce: *coerce}
+    }
+    opts.Include = includeOptions()
+    if *enums {
+        opts.Infer = &schema.InferOptions{Enums: true}
+    }
+    output, err := toml2x.ConvertWithOptions(*to, dataType, content, opts)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    if err := e.writeOutput(*out, output); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    return exitOK
+}
+
+// includeFlags 注册包含指令的选项，返回根据选项生成parser.Include的函数，未启用时生成nil
+func includeFlags(fs *flag.FlagSet, usage string) func() *parser.Include {
+    enabled := fs.Bool("include", false, usage)
+    root := fs.String("include-root", "", "directory included files must stay in, such as the repository root; defaults to the directory of each input file, implies --include")
+    return func() *parser.Include {
+        if !*enabled && *root == "" {
+            return nil
+        }
+        return &parser.Include{Root: *root}
+    }
+}
+
+// redactFlags 注册隐藏秘密的选项，返回根据选项生成redact.Options的函数，未启用时生成nil
+func redactFlags(fs *flag.FlagSet) func() (*redact.Options, error) {
+    enabled := fs.Bool("redact", false, "mask secrets: keys matching "+strings.Join(redact.DefaultPatterns, ",")+" and schema keys with secret = true")
+    patterns := fs.String("redact-patterns", "", "comma separated key path patterns used instead of the defaults, implies --redact")
+    hash := fs.Bool("redact-hash", false, "replace secrets with a short HMAC-SHA256 hash instead of ***, so equal secrets can be recognized; implies --redact")
+    hashKey := fs.String("redact-hash-key", "", "file with the HMAC key of --redact-hash, so hashes can be compared across runs; defaults to a random key per run, implies --redact-hash")
+    return func() (*redact.Options, error) {
+        if !*enabled && *patterns == "" && !*hash && *hashKey == "" {
+            return nil, nil
+        }
+        opts := &redact.Options{Patterns: splitList(*patterns), Hash: *hash || *hashKey != ""}
+        if *hashKey != "" {
+            key, err := ioutil.ReadFile(*hashKey)
+            if err != nil {
+                return nil, err
+            }
+            if opts.HashKey = bytes.TrimSpace(key); len(opts.HashKey) == 0 {
+                return nil, fmt.Errorf("%s: the hash key is empty", *hashKey)
+            }
+        }
+        return opts, nil
+    }
+}
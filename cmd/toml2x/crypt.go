@@ -0,0 +1,125 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/whencome/toml2x/crypt"
+    "github.com/whencome/toml2x/redact"
+)
+
+// keygen 生成密钥文件
+func (e *env) keygen(args []string) int {
+    fs := e.flagSet("keygen", "toml2x keygen [--out file]")
+    out := fs.String("out", "", "key file to create, defaults to stdout; an existing file is never overwritten")
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() > 0 {
+        return e.usageError(fs, "unexpected argument %q", fs.Arg(0))
+    }
+    key, err := crypt.GenerateKey()
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    if *out == "" || *out == "-" {
+        io.WriteString(e.stdout, key+"\n")
+        return exitOK
+    }
+    f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    defer f.Close()
+    if _, err := io.WriteString(f, key+"\n"); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    return exitOK
+}
+
+// encrypt 原样加密文件中选中的键的值并写回文件
+func (e *env) encrypt(args []string) int {
+    return e.crypt("encrypt", args)
+}
+
+// decrypt 原样解密文件中的值并写回文件
+func (e *env) decrypt(args []string) int {
+    return e.crypt("decrypt", args)
+}
+
+func (e *env) crypt(name string, args []string) int {
+    fs := e.flagSet(name, "toml2x "+name+" --key-file file [--keys list] [--schema file] [--out file] <file>")
+    keyFile := keyFileFlag(fs)
+    patterns := fs.String("keys", "", "comma separated key path patterns to "+name+", defaults to "+strings.Join(redact.DefaultPatterns, ",")+" for encrypt and every value for decrypt, none with --schema")
+    schemaFile := fs.String("schema", "", name+" the keys tagged with secret = true in a schema, together with the --keys patterns")
+    out := fs.String("out", "", "output file, defaults to the input file (stdout when reading stdin)")
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() != 1 {
+        return e.usageError(fs, "expected a file")
+    }
+    if *keyFile == "" {
+        return e.usageError(fs, "--key-file is required")
+    }
+    keys, err := crypt.NewKeyFile(*keyFile)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    sch, err := readSchema(*schemaFile)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    var match func(keys []string) bool
+    if *patterns != "" || sch != nil || name == "encrypt" {
+        opts := &redact.Options{Patterns: splitList(*patterns), Schema: sch}
+        if opts.Patterns == nil && sch != nil {
+            // 只指定了schema时只处理schema中的秘密
+            opts.Patterns = []string{}
+        }
+        match = opts.Match
+    }
+    content, input, err := e.readInput(fs.Arg(0))
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    var output string
+    if name == "encrypt" {
+        output, err = crypt.EncryptDocument(content, match, keys)
+    } else {
+        output, err = crypt.DecryptDocument(content, match, keys)
+    }
+    if err != nil {
+        return e.fail(fs.Name(), errors.New(input+": "+err.Error()))
+    }
+    path := *out
+    if path == "" && input != stdinName {
+        path = fs.Arg(0)
+    }
+    if err := e.writeFile(path, output); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    return exitOK
+}
+
+// keyFileFlag 注册密钥文件的选项
+func keyFileFlag(fs *flag.FlagSet) *string {
+    return fs.String("key-file", "", "file with base64 or hex encoded 256-bit keys, one per line; the first one encrypts (see toml2x keygen)")
+}
+
+// splitList 拆分逗号分隔的列表，为空时返回nil
+func splitList(s string) []string {
+    if s == "" {
+        return nil
+    }
+    list := make([]string, 0)
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+    "github.com/whencome/toml2x"
+    "github.com/whencome/toml2x/diff"
+    "github.com/whencome/toml2x/redact"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// diff 比较两个文件的含义，输出新增、删除以及修改的键
+func (e *env) diff(args []string) int {
+    fs := e.flagSet("diff", "toml2x diff [--format text|json|patch] [--arrays ordered|unordered] [--exit-code] [--redact] [--redact-patterns list] [--redact-hash-key file] <old> <new>")
+    format := fs.String("format", "text", "output format: text, json or patch (RFC 6902 JSON Patch)")
+    arrays := fs.String("arrays", "ordered", "how arrays are compared: ordered or unordered")
+    exitCode := fs.Bool("exit-code", false, "exit with 1 when the documents differ")
+    redactOptions := redactFlags(fs)
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() != 2 {
+        return e.usageError(fs, "expected two files")
+    }
+    if fs.Arg(0) == fs.Arg(1) && (fs.Arg(0) == "-" || fs.Arg(0) == "") {
+        return e.usageError(fs, "only one of the files can be read from stdin")
+    }
+    opts := &diff.Options{}
+    switch *arrays {
+    case "ordered":
+        opts.Arrays = diff.Ordered
+    case "unordered":
+        opts.Arrays = diff.Unordered
+    default:
+        return e.usageError(fs, "unsupported array policy %q", *arrays)
+    }
+    if *format != "text" && *format != "json" && *format != "patch" {
+        return e.usageError(fs, "unsupported format %q", *format)
+    }
+    redactOpts, err := redactOptions()
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    if redactOpts != nil {
+        // 秘密总是替换为哈希，修改过的秘密仍然作为修改输出
+        redactOpts.Hash = true
+    }
+
+    docs := make([]*xtype.Object, 2)
+    for i, path := range fs.Args() {
+        content, name, err := e.readInput(path)
+        if err != nil {
+            return e.fail(fs.Name(), err)
+        }
+        if docs[i], err = toml2x.Parse("table", content, &toml2x.Options{File: name}); err != nil {
+            return e.fail(fs.Name(), err)
+        }
+        if redactOpts != nil {
+            docs[i] = redact.Redact(docs[i], redactOpts)
+        }
+    }
+    changes := diff.Compare(docs[0], docs[1], opts)
+
+    var output string
+    switch *format {
+    case "json":
+        output, err = diff.Json(changes)
+    case "patch":
+        output, err = diff.Patch(changes)
+    default:
+        output = diff.Text(changes)
+    }
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    if err := e.writeFile("", output); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    if *exitCode && len(changes) > 0 {
+        return exitError
+    }
+    return exitOK
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "errors"
+    "io"
+    "io/ioutil"
+    "os"
+    "strconv"
+
+    "github.com/whencome/toml2x"
+    "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// get 读取单个值，标量输出原始内容，表和数组输出json
+func (e *env) get(args []string) int {
+    fs := e.flagSet("get", "toml2x get [--json] <file> <path>")
+    asJson := fs.Bool("json", false, "print scalars as json too")
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() != 2 {
+        return e.usageError(fs, "expected a file and a path")
+    }
+    content, name, err := e.readInput(fs.Arg(0))
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    obj, err := toml2x.Get(content, fs.Arg(1), &toml2x.Options{File: name})
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    output := util.String(obj.Value)
+    if *asJson || obj.Type == xtype.TypeMap {
+        output = obj.Json(false)
+    }
+    if err := e.writeOutput("", output); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    return exitOK
+}
+
+// set 修改单个值并写回文件，尽量保留注释以及格式
+func (e *env) set(args []string) int {
+    fs := e.flagSet("set", "toml2x set [--type auto|string|int|float|bool|datetime|toml] [--out file] <file> <path> <value>")
+    typ := fs.String("type", "auto", "type of the value; auto parses it as a toml value and falls back to string")
+    out := fs.String("out", "", "output file, defaults to the input file (stdout when reading stdin)")
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() != 3 {
+        return e.usageError(fs, "expected a file, a path and a value")
+    }
+    value, err := typedValue(*typ, fs.Arg(2))
+    if err != nil {
+        return e.usageError(fs, "%s", err)
+    }
+    content, name, err := e.readInput(fs.Arg(0))
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    output, err := toml2x.Set(content, fs.Arg(1), value, &toml2x.Options{File: name})
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+
+    path := *out
+    if path == "" && name != stdinName {
+        path = fs.Arg(0)
+    }
+    if err := e.writeFile(path, output); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    return exitOK
+}
+
+// writeFile 原样写入文件，path为空或者为-时写入标准输出，已存在的文件保留其权限
+func (e *env) writeFile(path string, content string) error {
+    if path == "" || path == "-" {
+        _, err := io.WriteString(e.stdout, content)
+        return err
+    }
+    mode := os.FileMode(0644)
+    if info, err := os.Stat(path); err == nil {
+        mode = info.Mode()
+    }
+    return ioutil.WriteFile(path, []byte(content), mode)
+}
+
+// typedValue 将命令行参数转换为指定类型的值
+func typedValue(typ string, value string) (*xtype.Object, error) {
+    switch typ {
+    case "auto":
+        if obj, err := parser.ParseSingle(value); err == nil {
+            return obj, nil
+        }
+        return xtype.NewStringObject(value), nil
+    case "string":
+        return xtype.NewStringObject(value), nil
+    case "int":
+        if !util.IsNumeric(value) {
+            return nil, errors.New("invalid int value: " + value)
+        }
+        if _, err := strconv.ParseInt(util.NormalizeNumber(value), 10, 64); err != nil {
+            return nil, errors.New("invalid int value: " + value)
+        }
+        return xtype.NewNumberObject(value), nil
+    case "float":
+        if !util.IsNumeric(value) {
+            return nil, errors.New("invalid float value: " + value)
+        }
+        return xtype.NewNumberObject(value), nil
+    case "bool":
+        if value != "true" && value != "false" {
+            return nil, errors.New("invalid bool value: " + value)
+        }
+        return xtype.NewBoolObject(value), nil
+    case "datetime":
+        if !util.IsDatetime(value) {
+            return nil, errors.New("invalid datetime value: " + value)
+        }
+        return xtype.NewDatetimeObject(value), nil
+    case "toml":
+        return parser.ParseSingle(value)
+    }
+    return nil, errors.New("unsupported type: " + typ)
+}
@@ -0,0 +1,153 @@
+/**
+ * command line tool of toml2x.
+ * exit codes: 0 success, 1 syntax or input error, 2 usage error.
+ * errors of toml documents are printed as file:line:col: message.
+ */
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/parser"
+)
+
+// define exit codes
+const (
+    exitOK    = 0
+    exitError = 1 // 语法错误或者读写文件失败
+    exitUsage = 2 // 命令行参数错误
+)
+
+// stdinName 从标准输入读取时，错误信息中使用的文件名
+const stdinName = "<stdin>"
+
+const usage = `Usage: toml2x <command> [options]
+
+Commands:
+  convert   convert toml to json, xml, php, toml or yaml
+  validate  check toml files and report every issue
+  get       print the value of a key
+  set       change the value of a key, keeping comments and layout
+  diff      compare two files by meaning instead of text
+  merge     merge layered files, later files override earlier ones
+  encrypt   encrypt the values of secret keys in place
+  decrypt   decrypt encrypted values in place
+  keygen    generate a key file for encrypt and decrypt
+
+Run 'toml2x <command> -h' for the options of a command.
+`
+
+// env 命令的运行环境
+type env struct {
+    stdin  io.Reader
+    stdout io.Writer
+    stderr io.Writer
+}
+
+func main() {
+    os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 执行命令并返回退出码
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+    e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+    if len(args) == 0 {
+        fmt.Fprint(stderr, usage)
+        return exitUsage
+    }
+    switch args[0] {
+    case "convert":
+        return e.convert(args[1:])
+    case "validate":
+        return e.validate(args[1:])
+    case "get":
+        return e.get(args[1:])
+    case "set":
+        return e.set(args[1:])
+    case "diff":
+        return e.diff(args[1:])
+    case "merge":
+        return e.merge(args[1:])
+    case "encrypt":
+        return e.encrypt(args[1:])
+    case "decrypt":
+        return e.decrypt(args[1:])
+    case "keygen":
+        return e.keygen(args[1:])
+    case "help", "-h", "-help", "--help":
+        fmt.Fprint(stdout, usage)
+        return exitOK
+    }
+    fmt.Fprintf(stderr, "toml2x: unknown command %q\n\n%s", args[0], usage)
+    return exitUsage
+}
+
+// flagSet 创建子命令的参数解析器
+func (e *env) flagSet(name string, synopsis string) *flag.FlagSet {
+    fs := flag.NewFlagSet(name, flag.ContinueOnError)
+    fs.SetOutput(e.stderr)
+    fs.Usage = func() {
+        fmt.Fprintf(e.stderr, "Usage: %s\n\nOptions:\n", synopsis)
+        fs.PrintDefaults()
+    }
+    return fs
+}
+
+// parseFlags 解析参数，返回非负数时命令应以该退出码结束
+func (e *env) parseFlags(fs *flag.FlagSet, args []string) int {
+    if err := fs.Parse(args); err != nil {
+        if err == flag.ErrHelp {
+            return exitOK
+        }
+        return exitUsage
+    }
+    return -1
+}
+
+// usageError 输出参数错误并返回对应的退出码
+func (e *env) usageError(fs *flag.FlagSet, format string, args ...interface{}) int {
+    fmt.Fprintf(e.stderr, "toml2x %s: %s\n", fs.Name(), fmt.Sprintf(format, args...))
+    fs.Usage()
+    return exitUsage
+}
+
+// fail 输出错误并返回对应的退出码
+// 文档中的错误已经以 file:line:col: 开头，其他错误加上命令名作为前缀
+func (e *env) fail(cmd string, err error) int {
+    var syntaxErr *parser.SyntaxError
+    var limitErr *formatter.LimitError
+    if errors.As(err, &syntaxErr) || errors.As(err, &limitErr) {
+        fmt.Fprintln(e.stderr, err)
+    } else {
+        fmt.Fprintf(e.stderr, "toml2x %s: %s\n", cmd, err)
+    }
+    return exitError
+}
+
+// readInput 读取输入文件，path为空或者为-时读取标准输入，同时返回用于错误信息的文件名
+func (e *env) readInput(path string) (string, string, error) {
+    if path == "" || path == "-" {
+        content, err := ioutil.ReadAll(e.stdin)
+        return string(content), stdinName, err
+    }
+    content, err := ioutil.ReadFile(path)
+    return string(content), path, err
+}
+
+// writeOutput 写入输出文件，path为空或者为-时写入标准输出，内容末尾确保有换行
+func (e *env) writeOutput(path string, content string) error {
+    if content != "" && content[len(content)-1] != '\n' {
+        content += "\n"
+    }
+    if path == "" || path == "-" {
+        _, err := io.WriteString(e.stdout, content)
+        return err
+    }
+    return ioutil.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCommand 执行命令，返回退出码、标准输出以及标准错误的内容
+func runCommand(stdin string, args ...string) (int, string, string) {
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		stdin  string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"a = 1\nb = 'x'\n", []string{"convert"}, exitOK, "{\"a\":1,\"b\":\"x\"}\n", ""},
+		{"a = 1\n", []string{"convert", "--to", "xml", "--table"}, exitOK, "<xml><table><a><![CDATA[1]]></a></table></xml>\n", ""},
+		{"'hello'", []string{"convert", "--to", "php", "--single"}, exitOK, "'hello'\n", ""},
+		{"a = 1\nb = \n", []string{"convert"}, exitError, "", "<stdin>:2:5: "},
+		{"a = [[[1]]]", []string{"convert", "--in", "-"}, exitOK, "{\"a\":[[[1]]]}\n", ""},
+		{"", []string{"convert", "--to", "csv"}, exitUsage, "", "unsupported format"},
+		{"", []string{"convert", "--single", "--table"}, exitUsage, "", "can not be used together"},
+		{"", []string{"convert", "--unknown"}, exitUsage, "", "flag provided but not defined"},
+		{"", []string{"convert", "extra"}, exitUsage, "", "unexpected argument"},
+		{"", []string{"convert", "--in", "missing.toml"}, exitError, "", "toml2x convert: "},
+		{"", []string{"unknown"}, exitUsage, "", "unknown command"},
+		{"", []string{}, exitUsage, "", "Usage: toml2x"},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(c.stdin, c.args...)
+		if code != c.code || (c.stdout != "" && stdout != c.stdout) || !strings.Contains(stderr, c.stderr) {
+			t.Logf("toml2x %s: expected %d %q %q, got %d %q %q\n", strings.Join(c.args, " "), c.code, c.stdout, c.stderr, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+}
+
+func TestConvertFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in.toml")
+	out := filepath.Join(dir, "out.json")
+	if err := ioutil.WriteFile(in, []byte("[server]\nport = 8080\nhost = \n"), 0644); err != nil {
+		t.Fatalf("write file failed: %s\n", err)
+	}
+	code, _, stderr := runCommand("", "convert", "--in", in, "--out", out)
+	if code != exitError || !strings.HasPrefix(stderr, in+":3:8: ") {
+		t.Logf("expected syntax error with file position, got %d %q\n", code, stderr)
+		t.Fail()
+	}
+
+	if err := ioutil.WriteFile(in, []byte("[server]\nport = 8080\n"), 0644); err != nil {
+		t.Fatalf("write file failed: %s\n", err)
+	}
+	if code, _, stderr := runCommand("", "convert", "--in", in, "--out", out); code != exitOK {
+		t.Logf("convert failed: %d %s\n", code, stderr)
+		t.Fail()
+	}
+	content, err := ioutil.ReadFile(out)
+	if err != nil || string(content) != "{\"server\":{\"port\":8080}}\n" {
+		t.Logf("unexpected output: %q %v\n", content, err)
+		t.Fail()
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	good := filepath.Join(dir, "good.toml")
+	bad := filepath.Join(dir, "bad.toml")
+	ioutil.WriteFile(good, []byte("a = 1\n"), 0644)
+	ioutil.WriteFile(bad, []byte("a = 1\nb = \nc = [1, 2\n[d\ne = \"ok\"\nf = 'x' y\n"), 0644)
+
+	code, stdout, _ := runCommand("", "validate", good)
+	if code != exitOK || stdout != "" {
+		t.Logf("validate good file: expected no issues, got %d %q\n", code, stdout)
+		t.Fail()
+	}
+
+	code, stdout, _ = runCommand("", "validate", good, bad)
+	expected := bad + ":2:5: expected value, got newline\n" +
+		bad + ":4:1: expected ',' or ']', got \"[\"\n" +
+		bad + ":6:9: expected newline, got \"y\"\n"
+	if code != exitError || stdout != expected {
+		t.Logf("validate bad file: expected %q, got %d %q\n", expected, code, stdout)
+		t.Fail()
+	}
+
+	formats := map[string]string{
+		"json":       "\"file\": \"<stdin>\",\n    \"line\": 1,\n    \"column\": 5,",
+		"github":     "::error file=<stdin>,line=1,col=5::expected value, got end of file\n",
+		"checkstyle": "<file name=\"&lt;stdin&gt;\">\n    <error line=\"1\" column=\"5\" severity=\"error\" message=\"expected value, got end of file\" source=\"toml2x\"/>",
+	}
+	for format, contains := range formats {
+		code, stdout, _ := runCommand("a = ", "validate", "--format", format)
+		if code != exitError || !strings.Contains(stdout, contains) {
+			t.Logf("validate --format %s: expected %q, got %d %q\n", format, contains, code, stdout)
+			t.Fail()
+		}
+	}
+	if code, _, _ := runCommand("", "validate", "--format", "xml"); code != exitUsage {
+		t.Logf("expected usage error for unsupported format, got %d\n", code)
+		t.Fail()
+	}
+}
+
+func TestGet(t *testing.T) {
+	doc := "title = \"demo\"\n[server]\nport = 8080\nports = [1, 2]\n"
+	cases := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"get", "-", "title"}, exitOK, "demo\n"},
+		{[]string{"get", "--json", "-", "title"}, exitOK, "\"demo\"\n"},
+		{[]string{"get", "-", "server.port"}, exitOK, "8080\n"},
+		{[]string{"get", "-", "server.ports"}, exitOK, "[1,2]\n"},
+		{[]string{"get", "-", "server.ports[1]"}, exitOK, "2\n"},
+		{[]string{"get", "-", "server"}, exitOK, "{\"port\":8080,\"ports\":[1,2]}\n"},
+		{[]string{"get", "-", "missing"}, exitError, ""},
+		{[]string{"get", "-"}, exitUsage, ""},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(doc, c.args...)
+		if code != c.code || stdout != c.stdout {
+			t.Logf("toml2x %s: expected %d %q, got %d %q %q\n", strings.Join(c.args, " "), c.code, c.stdout, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	doc := "# app config\ntitle = \"demo\" # the title\n\n[server]\n# listen port\nport = 8080\nports = [1, 2]\n"
+	cases := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"set", "-", "server.port", "9090"}, exitOK, strings.Replace(doc, "8080", "9090", 1)},
+		{[]string{"set", "-", "title", "new title"}, exitOK, strings.Replace(doc, "\"demo\"", "\"new title\"", 1)},
+		{[]string{"set", "--type", "string", "-", "server.port", "9090"}, exitOK, strings.Replace(doc, "8080", "\"9090\"", 1)},
+		{[]string{"set", "--type", "bool", "-", "server.debug", "true"}, exitOK, doc + "debug = true\n"},
+		{[]string{"set", "-", "server.ports[1]", "3"}, exitOK, strings.Replace(doc, "[1, 2]", "[1, 3]", 1)},
+		{[]string{"set", "-", "server.tls.cert", "a.pem"}, exitOK, doc + "tls.cert = \"a.pem\"\n"},
+		{[]string{"set", "--type", "int", "-", "server.port", "abc"}, exitUsage, ""},
+		{[]string{"set", "--type", "uint", "-", "server.port", "1"}, exitUsage, ""},
+		{[]string{"set", "-", "title.sub", "1"}, exitError, ""},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(doc, c.args...)
+		if code != c.code || stdout != c.stdout {
+			t.Logf("toml2x %s: expected %d %q, got %d %q %q\n", strings.Join(c.args, " "), c.code, c.stdout, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "app.toml")
+	if err := ioutil.WriteFile(in, []byte("# db\n[db]\nhost = 'localhost' # local\n"), 0600); err != nil {
+		t.Fatalf("write file failed: %s\n", err)
+	}
+	if code, stdout, stderr := runCommand("", "set", in, "db.host", "example.com"); code != exitOK || stdout != "" {
+		t.Logf("set failed: %d %q %q\n", code, stdout, stderr)
+		t.Fail()
+	}
+	content, err := ioutil.ReadFile(in)
+	if err != nil || string(content) != "# db\n[db]\nhost = \"example.com\" # local\n" {
+		t.Logf("unexpected content: %q %v\n", content, err)
+		t.Fail()
+	}
+	if info, err := os.Stat(in); err != nil || info.Mode().Perm() != 0600 {
+		t.Logf("file mode should be kept: %v %v\n", info.Mode(), err)
+		t.Fail()
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	old := filepath.Join(dir, "old.toml")
+	ioutil.WriteFile(old, []byte("# staging\n[db]\nhost = 'db1'\nport = 5432\ntags = ['a', 'b']\n"), 0644)
+	stdin := "[db]\ntags = ['b', 'a']\nport = 5_432\nhost = 'db2'\n"
+	secret1 := filepath.Join(dir, "secret1.toml")
+	secret2 := filepath.Join(dir, "secret2.toml")
+	key := filepath.Join(dir, "hash.key")
+	ioutil.WriteFile(secret1, []byte("[db]\npassword = 'hunter2'\n"), 0644)
+	ioutil.WriteFile(secret2, []byte("[db]\npassword = 'hunter3'\n"), 0644)
+	ioutil.WriteFile(key, []byte("test-key\n"), 0600)
+
+	cases := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"diff", old, "-"}, exitOK, "~ db.host: \"db1\" -> \"db2\"\n~ db.tags[0]: \"a\" -> \"b\"\n~ db.tags[1]: \"b\" -> \"a\"\n"},
+		{[]string{"diff", "--arrays", "unordered", old, "-"}, exitOK, "~ db.host: \"db1\" -> \"db2\"\n"},
+		{[]string{"diff", "--arrays", "unordered", "--format", "patch", old, "-"}, exitOK, "[\n  {\n    \"op\": \"replace\",\n    \"path\": \"/db/host\",\n    \"value\": \"db2\"\n  }\n]\n"},
+		{[]string{"diff", "--arrays", "unordered", "--exit-code", old, "-"}, exitError, ""},
+		{[]string{"diff", "--exit-code", old, old}, exitOK, ""},
+		{[]string{"diff", "--format", "json", old, old}, exitOK, "[]\n"},
+		{[]string{"diff", "--redact-hash-key", key, secret1, secret2}, exitOK, "~ db.password: \"hmac:65f93b070e9be4bc\" -> \"hmac:13d6edc0d1d4a24a\"\n"},
+		{[]string{"diff", "--redact", "--exit-code", secret1, secret2}, exitError, ""},
+		{[]string{"diff", "--redact", "--exit-code", secret1, secret1}, exitOK, ""},
+		{[]string{"diff", "--arrays", "sorted", old, "-"}, exitUsage, ""},
+		{[]string{"diff", "-", "-"}, exitUsage, ""},
+		{[]string{"diff", old}, exitUsage, ""},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(stdin, c.args...)
+		if code != c.code || (c.stdout != "" && stdout != c.stdout) {
+			t.Logf("toml2x %s: expected %d %q, got %d %q %q\n", strings.Join(c.args, " "), c.code, c.stdout, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+	if code, stdout, _ := runCommand(stdin, "diff", "--exit-code", old, old); stdout != "" || code != exitOK {
+		t.Logf("identical files: expected no output, got %d %q\n", code, stdout)
+		t.Fail()
+	}
+	if _, stdout, _ := runCommand("", "diff", "--redact", "--format", "patch", secret1, secret2); strings.Contains(stdout, "hunter") || !strings.Contains(stdout, "hmac:") {
+		t.Logf("diff --redact: secrets should be hashed, got %q\n", stdout)
+		t.Fail()
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "base.toml")
+	prod := filepath.Join(dir, "prod.toml")
+	local := filepath.Join(dir, "local.toml")
+	ioutil.WriteFile(base, []byte("debug = true\n[db]\nhost = 'localhost'\n[[users]]\nname = 'a'\nrole = 'dev'\n"), 0644)
+	ioutil.WriteFile(prod, []byte("debug = '-'\n[db]\nhost = 'db.prod'\n[[users]]\nname = 'a'\nrole = 'ops'\n"), 0644)
+	secret := filepath.Join(dir, "secret.toml")
+	key := filepath.Join(dir, "hash.key")
+	ioutil.WriteFile(secret, []byte("[db]\npassword = 's3cret'\n"), 0644)
+	ioutil.WriteFile(key, []byte("test-key"), 0600)
+
+	cases := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"merge", "--to", "json", base, prod}, exitOK, "{\"debug\":\"-\",\"db\":{\"host\":\"db.prod\"},\"users\":[{\"name\":\"a\",\"role\":\"ops\"}]}\n"},
+		{[]string{"merge", "--to", "json", "--arrays", "append", "--delete-marker", "-", base, prod}, exitOK, "{\"db\":{\"host\":\"db.prod\"},\"users\":[{\"name\":\"a\",\"role\":\"dev\"},{\"name\":\"a\",\"role\":\"ops\"}]}\n"},
+		{[]string{"merge", "--to", "json", "--arrays", "merge-by-key", "--key", "name", base, prod}, exitOK, "{\"debug\":\"-\",\"db\":{\"host\":\"db.prod\"},\"users\":[{\"name\":\"a\",\"role\":\"ops\"}]}\n"},
+		{[]string{"merge", "--provenance", base, prod}, exitOK, prod + ":1:9: debug\n" + prod + ":3:8: db.host\n" + prod + ":5:8: users[0].name\n" + prod + ":6:8: users[0].role\n"},
+		{[]string{"merge", "--missing-ok", "--to", "json", base, local}, exitOK, "{\"debug\":true,\"db\":{\"host\":\"localhost\"},\"users\":[{\"name\":\"a\",\"role\":\"dev\"}]}\n"},
+		{[]string{"merge", base, local}, exitError, ""},
+		{[]string{"merge", "--to", "json", "--redact", base, secret}, exitOK, "{\"debug\":true,\"db\":{\"host\":\"localhost\",\"password\":\"***\"},\"users\":[{\"name\":\"a\",\"role\":\"dev\"}]}\n"},
+		{[]string{"merge", "--to", "json", "--redact-hash-key", key, secret}, exitOK, "{\"db\":{\"password\":\"hmac:aab8b7b90bba7871\"}}\n"},
+		{[]string{"merge", "--redact-hash-key", filepath.Join(dir, "missing.key"), secret}, exitError, ""},
+		{[]string{"merge", "--arrays", "union", base}, exitUsage, ""},
+		{[]string{"merge"}, exitUsage, ""},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand("", c.args...)
+		if code != c.code || (c.stdout != "" && stdout != c.stdout) {
+			t.Logf("toml2x %s: expected %d %q, got %d %q %q\n", strings.Join(c.args, " "), c.code, c.stdout, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+}
+
+func TestConvertEnv(t *testing.T) {
+	os.Setenv("TOML2X_TEST_PORT", "9090")
+	defer os.Unsetenv("TOML2X_TEST_PORT")
+	doc := "host = \"${TOML2X_TEST_HOST:-localhost}\"\nport = ${TOML2X_TEST_PORT}\n"
+	cases := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"convert", "--env"}, exitOK, "{\"host\":\"localhost\",\"port\":\"9090\"}\n", ""},
+		{[]string{"convert", "--env", "--coerce"}, exitOK, "{\"host\":\"localhost\",\"port\":9090}\n", ""},
+		{[]string{"convert"}, exitError, "", "<stdin>:2:8: "},
+		{[]string{"convert", "--coerce"}, exitUsage, "", "--coerce requires --env"},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(doc, c.args...)
+		if code != c.code || stdout != c.stdout || !strings.Contains(stderr, c.stderr) {
+			t.Logf("toml2x %s: expected %d %q %q, got %d %q %q\n", strings.Join(c.args, " "), c.code, c.stdout, c.stderr, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+	code, _, stderr := runCommand("a = \"${TOML2X_TEST_MISSING}\"\n", "convert", "--env")
+	if code != exitError || stderr != "<stdin>:1:6: variable TOML2X_TEST_MISSING is not set\n" {
+		t.Logf("expected unresolved variable error, got %d %q\n", code, stderr)
+		t.Fail()
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "shared"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "shared", "common.toml"), []byte("[db]\nhost = 'localhost'\nport = 5432\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "shared", "broken.toml"), []byte("a = \n"), 0644)
+	app := filepath.Join(dir, "app.toml")
+	bad := filepath.Join(dir, "bad.toml")
+	ioutil.WriteFile(app, []byte("include = ['shared/common.toml']\n[db]\nhost = 'db.app'\n"), 0644)
+	ioutil.WriteFile(bad, []byte("include = 'shared/broken.toml'\n"), 0644)
+
+	code, stdout, stderr := runCommand("", "convert", "--include", "--in", app)
+	if code != exitOK || stdout != "{\"db\":{\"host\":\"db.app\",\"port\":5432}}\n" {
+		t.Logf("convert --include: unexpected %d %q %q\n", code, stdout, stderr)
+		t.Fail()
+	}
+	code, stdout, _ = runCommand("", "validate", "--include", app, bad)
+	if expected := filepath.Join(dir, "shared", "broken.toml") + ":1:5: expected value, got newline\n"; code != exitError || stdout != expected {
+		t.Logf("validate --include: expected %q, got %d %q\n", expected, code, stdout)
+		t.Fail()
+	}
+	svc := filepath.Join(dir, "svc", "api.toml")
+	os.Mkdir(filepath.Join(dir, "svc"), 0755)
+	ioutil.WriteFile(svc, []byte("include = '../shared/common.toml'\n"), 0644)
+	if code, stdout, stderr := runCommand("", "convert", "--include-root", dir, "--in", svc); code != exitOK || stdout != "{\"db\":{\"host\":\"localhost\",\"port\":5432}}\n" {
+		t.Logf("convert --include-root: unexpected %d %q %q\n", code, stdout, stderr)
+		t.Fail()
+	}
+	if code, _, stderr := runCommand("", "convert", "--include", "--in", svc); code != exitError || !strings.Contains(stderr, "outside the include root") {
+		t.Logf("convert --include: expected an error outside the root, got %d %q\n", code, stderr)
+		t.Fail()
+	}
+	code, stdout, _ = runCommand("", "validate", "--include", "--format", "checkstyle", app, bad)
+	expected := "<checkstyle version=\"4.3\">\n  <file name=\"" + app + "\">\n  </file>\n  <file name=\"" + bad + "\">\n  </file>\n" +
+		"  <file name=\"" + filepath.Join(dir, "shared", "broken.toml") + "\">\n" +
+		"    <error line=\"1\" column=\"5\" severity=\"error\" message=\"expected value, got newline\" source=\"toml2x\"/>\n  </file>\n</checkstyle>\n"
+	if code != exitError || !strings.HasSuffix(stdout, expected) {
+		t.Logf("validate --include --format checkstyle: expected %q, got %d %q\n", expected, code, stdout)
+		t.Fail()
+	}
+}
+
+func TestConvertProfile(t *testing.T) {
+	doc := "port = 80\n[profile.prod]\nport = 443\n"
+	if code, stdout, stderr := runCommand(doc, "convert", "--profile", "prod"); code != exitOK || stdout != "{\"port\":443}\n" {
+		t.Logf("convert --profile prod: unexpected %d %q %q\n", code, stdout, stderr)
+		t.Fail()
+	}
+	code, _, stderr := runCommand(doc, "convert", "--profile", "dev")
+	if code != exitError || stderr != "toml2x convert: profile \"dev\" not found, available: profile.prod\n" {
+		t.Logf("convert --profile dev: unexpected %d %q\n", code, stderr)
+		t.Fail()
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	jsonSchema := filepath.Join(dir, "schema.json")
+	tomlSchema := filepath.Join(dir, "schema.toml")
+	ioutil.WriteFile(jsonSchema, []byte(`{"type": "object", "required": ["name"], "properties": {"port": {"type": "integer", "maximum": 65535}}}`), 0644)
+	ioutil.WriteFile(tomlSchema, []byte("type = 'object'\nrequired = ['name']\nproperties.port = { type = 'integer', maximum = 65535 }\n"), 0644)
+
+	for _, file := range []string{jsonSchema, tomlSchema} {
+		code, stdout, _ := runCommand("port = 70000\n", "validate", "--schema", file)
+		expected := "<stdin>: name: required key is missing\n<stdin>:1:8: port: value 70000 is greater than maximum 65535\n"
+		if code != exitError || stdout != expected {
+			t.Logf("validate --schema %s: expected %q, got %d %q\n", file, expected, code, stdout)
+			t.Fail()
+		}
+		if code, stdout, _ := runCommand("name = 'app'\nport = 80\n", "validate", "--schema", file); code != exitOK || stdout != "" {
+			t.Logf("validate --schema %s: expected no issues, got %d %q\n", file, code, stdout)
+			t.Fail()
+		}
+	}
+	ioutil.WriteFile(jsonSchema, []byte(`{"type": "float"}`), 0644)
+	code, _, stderr := runCommand("", "validate", "--schema", jsonSchema)
+	if expected := "toml2x validate: " + jsonSchema + ":1:10: unknown type float\n"; code != exitError || stderr != expected {
+		t.Logf("validate with invalid schema: expected %q, got %d %q\n", expected, code, stderr)
+		t.Fail()
+	}
+}
+
+func TestConvertSchema(t *testing.T) {
+	code, stdout, stderr := runCommand("mode = 'dev'\n", "convert", "--to", "schema", "--enums")
+	expected := "{\n  \"$schema\": \"https://json-schema.org/draft/2020-12/schema\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"mode\": {\n      \"type\": \"string\",\n      \"enum\": [\n        \"dev\"\n      ]\n    }\n  },\n  \"required\": [\n    \"mode\"\n  ]\n}\n"
+	if code != exitOK || stdout != expected {
+		t.Logf("convert --to schema: expected %q, got %d %q %q\n", expected, code, stdout, stderr)
+		t.Fail()
+	}
+	if code, _, _ := runCommand("", "convert", "--enums"); code != exitUsage {
+		t.Logf("expected usage error for --enums without --to schema, got %d\n", code)
+		t.Fail()
+	}
+}
+
+func TestConvertWithSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "schema.json")
+	ioutil.WriteFile(file, []byte(`{"properties": {"port": {"type": "integer", "default": 80}, "name": {"type": "string"}}}`), 0644)
+
+	code, stdout, stderr := runCommand("name = 1\n", "convert", "--to", "php", "--schema", file)
+	if expected := "array(\n    'name' => '1',\n    'port' => 80,\n)\n"; code != exitOK || stdout != expected {
+		t.Logf("convert --schema: expected %q, got %d %q %q\n", expected, code, stdout, stderr)
+		t.Fail()
+	}
+	code, _, stderr = runCommand("port = 'http'\n", "convert", "--schema", file)
+	if expected := "toml2x convert: <stdin>:1:8: port: expected integer, got string\n"; code != exitError || stderr != expected {
+		t.Logf("convert --schema with invalid value: expected %q, got %d %q\n", expected, code, stderr)
+		t.Fail()
+	}
+}
+
+func TestConvertRedact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	key := filepath.Join(dir, "hash.key")
+	ioutil.WriteFile(key, []byte("test-key\n"), 0600)
+	doc := "user = 'root'\npassword = 'hunter2'\n[api]\ntoken = 'abc'\n"
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--redact"}, "{\"user\":\"root\",\"password\":\"***\",\"api\":{\"token\":\"***\"}}\n"},
+		{[]string{"--redact-patterns", "user, api.*"}, "{\"user\":\"***\",\"password\":\"hunter2\",\"api\":{\"token\":\"***\"}}\n"},
+		{[]string{"--to", "toml", "--redact-hash-key", key, "--redact-patterns", "password"}, "user     = \"root\"\npassword = \"hmac:65f93b070e9be4bc\"\n\n[api]\ntoken = \"abc\"\n"},
+	}
+	for _, c := range cases {
+		code, stdout, stderr := runCommand(doc, append([]string{"convert"}, c.args...)...)
+		if code != exitOK || stdout != c.expected {
+			t.Logf("convert %v: expected %q, got %d %q %q\n", c.args, c.expected, code, stdout, stderr)
+			t.Fail()
+		}
+	}
+	code, stdout, stderr := runCommand(doc, "convert", "--redact-hash")
+	if code != exitOK || !strings.Contains(stdout, "\"password\":\"hmac:") || strings.Contains(stdout, "hmac:65f93b070e9be4bc") {
+		t.Logf("convert --redact-hash should use a random key, got %d %q %q\n", code, stdout, stderr)
+		t.Fail()
+	}
+	code, _, stderr = runCommand("password = hunter2\n", "convert", "--redact")
+	if expected := "<stdin>:1:12: invalid value ***\n"; code != exitError || stderr != expected {
+		t.Logf("convert --redact with syntax error: expected %q, got %d %q\n", expected, code, stderr)
+		t.Fail()
+	}
+	code, stdout, _ = runCommand("password = 'a' 'b'\n", "validate", "--redact")
+	if expected := "<stdin>:1:16: expected newline, got \"***\"\n"; code != exitError || stdout != expected {
+		t.Logf("validate --redact: expected %q, got %d %q\n", expected, code, stdout)
+		t.Fail()
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	if code, _, stderr := runCommand("", "keygen", "--out", keyFile); code != exitOK {
+		t.Fatalf("keygen failed: %d %s\n", code, stderr)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Logf("expected a key file readable only by its owner, got %v %v\n", info, err)
+		t.Fail()
+	}
+	if code, _, _ := runCommand("", "keygen", "--out", keyFile); code != exitError {
+		t.Logf("expected keygen to refuse overwriting a key file, got %d\n", code)
+		t.Fail()
+	}
+
+	doc := "user = 'root'\npassword = 'hunter2' # db\nport = 5432\n"
+	file := filepath.Join(dir, "app.toml")
+	ioutil.WriteFile(file, []byte(doc), 0644)
+	if code, _, stderr := runCommand("", "encrypt", "--key-file", keyFile, "--keys", "password,port", file); code != exitOK {
+		t.Fatalf("encrypt failed: %d %s\n", code, stderr)
+	}
+	content, _ := ioutil.ReadFile(file)
+	if strings.Contains(string(content), "hunter2") || strings.Contains(string(content), "5432") || !strings.HasPrefix(string(content), "user = 'root'\npassword = \"ENC[AES256_GCM,") {
+		t.Logf("unexpected encrypted file\n%s\n", content)
+		t.Fail()
+	}
+	code, stdout, stderr := runCommand("", "convert", "--key-file", keyFile, "--in", file)
+	if expected := "{\"user\":\"root\",\"password\":\"hunter2\",\"port\":5432}\n"; code != exitOK || stdout != expected {
+		t.Logf("convert --key-file: expected %q, got %d %q %q\n", expected, code, stdout, stderr)
+		t.Fail()
+	}
+	if code, stdout, _ := runCommand("", "decrypt", "--key-file", keyFile, "--out", "-", file); code != exitOK || stdout != doc {
+		t.Logf("decrypt: expected %q, got %d %q\n", doc, code, stdout)
+		t.Fail()
+	}
+	if code, _, _ := runCommand("", "encrypt", file); code != exitUsage {
+		t.Logf("expected usage error without --key-file, got %d\n", code)
+		t.Fail()
+	}
+
+	// 只指定schema时只加密schema中的秘密，行内表中的值也会加密
+	schemaFile := filepath.Join(dir, "schema.json")
+	ioutil.WriteFile(schemaFile, []byte(`{"properties": {"db": {"properties": {"pin": {"secret": true}}}}}`), 0644)
+	code, stdout, stderr = runCommand("password = 'hunter2'\ndb = { pin = 1234, token = 'abc' }\n", "encrypt", "--key-file", keyFile, "--schema", schemaFile, "-")
+	if code != exitOK || !strings.HasPrefix(stdout, "password = 'hunter2'\ndb = { pin = \"ENC[AES256_GCM,") || !strings.HasSuffix(stdout, "]\", token = 'abc' }\n") {
+		t.Logf("encrypt --schema: got %d %q %q\n", code, stdout, stderr)
+		t.Fail()
+	}
+}
+
+func TestConvertYaml(t *testing.T) {
+	code, stdout, stderr := runCommand("name = 'app'\n[db]\nport = 5432\n", "convert", "--to", "yaml")
+	expected := "name: app\ndb:\n  port: 5432\n"
+	if code != exitOK || stdout != expected {
+		t.Logf("convert --to yaml: expected %q, got %d %q %q\n", expected, code, stdout, stderr)
+		t.Fail()
+	}
+}
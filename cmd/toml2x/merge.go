@@ -0,0 +1,88 @@
+package main
+
+import (
+    "bytes"
+    "os"
+
+    "github.com/whencome/toml2x"
+    "github.com/whencome/toml2x/merge"
+    "github.com/whencome/toml2x/redact"
+)
+
+// arrayStrategies 命令行中数组合并方式的名称
+var arrayStrategies = map[string]merge.ArrayStrategy{
+    "replace":      merge.Replace,
+    "append":       merge.Append,
+    "merge-by-key": merge.MergeByKey,
+}
+
+// merge 依次合并多个文件，后面的文件覆盖前面的文件
+func (e *env) merge(args []string) int {
+    fs := e.flagSet("merge", "toml2x merge [--to json|xml|php|toml|yaml] [--arrays replace|append|merge-by-key] [--key name] [--delete-marker value] [--missing-ok] [--provenance] [--redact] [--redact-patterns list] [--redact-hash] [--redact-hash-key file] [--out file] <file> ...")
+    to := fs.String("to", "toml", "output format: json, xml, php, toml or yaml")
+    arrays := fs.String("arrays", "replace", "how arrays are merged: replace, append or merge-by-key")
+    key := fs.String("key", merge.DefaultKey, "field used to match array elements with --arrays merge-by-key")
+    marker := fs.String("delete-marker", "", "string value that removes a key from the result, disabled when empty")
+    missingOK := fs.Bool("missing-ok", false, "skip files that do not exist, such as an optional local layer")
+    provenance := fs.Bool("provenance", false, "print where every value of the result comes from instead of the result")
+    redactOptions := redactFlags(fs)
+    out := fs.String("out", "", "output file, defaults to stdout")
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    if fs.NArg() == 0 {
+        return e.usageError(fs, "expected at least one file")
+    }
+    strategy, ok := arrayStrategies[*arrays]
+    if !ok {
+        return e.usageError(fs, "unsupported array strategy %q", *arrays)
+    }
+    if !toml2x.IsFormat(*to) {
+        return e.usageError(fs, "unsupported format %q", *to)
+    }
+    redactOpts, err := redactOptions()
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+
+    layers := make([]merge.Layer, 0, fs.NArg())
+    for _, path := range fs.Args() {
+        content, name, err := e.readInput(path)
+        if err != nil {
+            if *missingOK && os.IsNotExist(err) {
+                continue
+            }
+            return e.fail(fs.Name(), err)
+        }
+        obj, err := toml2x.Parse("table", content, &toml2x.Options{File: name})
+        if err != nil {
+            return e.fail(fs.Name(), err)
+        }
+        layers = append(layers, merge.Layer{Name: name, Data: obj})
+    }
+    rs, err := merge.Merge(layers, &merge.Options{Arrays: strategy, Key: *key, DeleteMarker: *marker})
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+
+    var output string
+    if *provenance {
+        buf := bytes.Buffer{}
+        for _, s := range rs.Sources {
+            buf.WriteString(s.Pos.String() + ": " + s.Path + "\n")
+        }
+        output = buf.String()
+    } else {
+        data := rs.Data
+        if redactOpts != nil {
+            data = redact.Redact(data, redactOpts)
+        }
+        if output, err = toml2x.Format(*to, data); err != nil {
+            return e.fail(fs.Name(), err)
+        }
+    }
+    if err := e.writeOutput(*out, output); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    return exitOK
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strings"
+
+    "github.com/whencome/toml2x"
+    "github.com/whencome/toml2x/schema"
+)
+
+// readSchema 读取json或者toml格式的schema，path为空时返回nil
+func readSchema(path string) (*schema.Schema, error) {
+    if path == "" {
+        return nil, nil
+    }
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return schema.Parse(path, string(content))
+}
+
+// validate 校验命令，检查一个或多个文件而不进行转换，存在问题时退出码为1
+func (e *env) validate(args []string) int {
+    fs := e.flagSet("validate", "toml2x validate [--format text|json|github|checkstyle] [--include] [--include-root dir] [--schema file] [--redact] [--redact-patterns list] [file ...]")
+    format := fs.String("format", "text", "report format: text, json, github or checkstyle")
+    includeOptions := includeFlags(fs, "resolve include = [\"file.toml\"] and validate the included files too")
+    schemaFile := fs.String("schema", "", "also check the documents against a schema written in json or toml")
+    redactOptions := redactFlags(fs)
+    if code := e.parseFlags(fs, args); code >= 0 {
+        return code
+    }
+    report, ok := reporters[*format]
+    if !ok {
+        return e.usageError(fs, "unsupported format %q", *format)
+    }
+    sch, err := readSchema(*schemaFile)
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    redactOpts, err := redactOptions()
+    if err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    files := fs.Args()
+    if len(files) == 0 {
+        files = []string{"-"}
+    }
+
+    results := make([]fileIssues, 0, len(files))
+    failed := false
+    for _, path := range files {
+        content, name, err := e.readInput(path)
+        var issues []toml2x.Issue
+        if err != nil {
+            issues = []toml2x.Issue{toml2x.NewIssue(name, err)}
+        } else {
+            opts := &toml2x.Options{File: name, Schema: sch, Redact: redactOpts, Include: includeOptions()}
+            issues = toml2x.Validate(content, opts)
+        }
+        failed = failed || len(issues) > 0
+        results = append(results, fileIssues{name: name, issues: issues})
+    }
+    if err := report(e.stdout, results); err != nil {
+        return e.fail(fs.Name(), err)
+    }
+    if failed {
+        return exitError
+    }
+    return exitOK
+}
+
+// fileIssues 一个文件中发现的问题
+type fileIssues struct {
+    name   string
+    issues []toml2x.Issue
+}
+
+// reporters 各个格式的校验结果输出
+var reporters = map[string]func(w io.Writer, results []fileIssues) error{
+    "text":       reportText,
+    "json":       reportJson,
+    "github":     reportGithub,
+    "checkstyle": reportCheckstyle,
+}
+
+// reportText 每行输出一个问题：file:line:col: message
+func reportText(w io.Writer, results []fileIssues) error {
+    buf := bytes.Buffer{}
+    for _, rs := range results {
+        for _, issue := range rs.issues {
+            buf.WriteString(issue.String())
+            buf.WriteString("\n")
+        }
+    }
+    _, err := w.Write(buf.Bytes())
+    return err
+}
+
+// reportJson 输出问题列表的json数组
+func reportJson(w io.Writer, results []fileIssues) error {
+    issues := make([]toml2x.Issue, 0)
+    for _, rs := range results {
+        issues = append(issues, rs.issues...)
+    }
+    enc := json.NewEncoder(w)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", "  ")
+    return enc.Encode(issues)
+}
+
+// reportGithub 输出GitHub Actions的workflow命令，问题会作为注解显示在代码评审中
+func reportGithub(w io.Writer, results []fileIssues) error {
+    buf := bytes.Buffer{}
+    for _, rs := range results {
+        for _, issue := range rs.issues {
+            buf.WriteString("::error file=" + githubEscape(issue.File, true))
+            if issue.Line > 0 {
+                buf.WriteString(fmt.Sprintf(",line=%d,col=%d", issue.Line, issue.Column))
+            }
+            buf.WriteString("::" + githubEscape(issue.Message, false) + "\n")
+        }
+    }
+    _, err := w.Write(buf.Bytes())
+    return err
+}
+
+// githubEscape 转义workflow命令中的特殊字符，property为true时转义属性值
+func githubEscape(s string, property bool) string {
+    s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+    if property {
+        s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+    }
+    return s
+}
+
+// reportCheckstyle 输出checkstyle格式的xml，所有检查过的文件都会列出，
+// 问题按所在的文件分组，包含的文件中的问题列在该文件下
+func reportCheckstyle(w io.Writer, results []fileIssues) error {
+    names := make([]string, 0, len(results))
+    files := make(map[string][]toml2x.Issue)
+    add := func(name string) {
+        if _, ok := files[name]; !ok {
+            names = append(names, name)
+            files[name] = nil
+        }
+    }
+    for _, rs := range results {
+        add(rs.name)
+        for _, issue := range rs.issues {
+            name := issue.File
+            if name == "" {
+                name = rs.name
+            }
+            add(name)
+            files[name] = append(files[name], issue)
+        }
+    }
+    buf := bytes.Buffer{}
+    buf.WriteString(xml.Header)
+    buf.WriteString("<checkstyle version=\"4.3\">\n")
+    for _, name := range names {
+        buf.WriteString("  <file name=\"" + xmlEscape(name) + "\">\n")
+        for _, issue := range files[name] {
+            buf.WriteString(fmt.Sprintf("    <error line=\"%d\" column=\"%d\" severity=\"error\" message=\"%s\" source=\"toml2x\"/>\n",
+                issue.Line, issue.Column, xmlEscape(issue.Message)))
+        }
+        buf.WriteString("  </file>\n")
+    }
+    buf.WriteString("</checkstyle>\n")
+    _, err := w.Write(buf.Bytes())
+    return err
+}
+
+func xmlEscape(s string) string {
+    buf := bytes.Buffer{}
+    xml.EscapeText(&buf, []byte(s))
+    return buf.String()
+}
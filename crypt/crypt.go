@@ -0,0 +1,150 @@
+/**
+ * encrypted values in toml documents.
+ * a value is encrypted as a string ENC[AES256_GCM,key:<id>,data:<base64>] whose plaintext is the original toml literal,
+ * so decrypted values keep their type. the key path of the value is bound as additional data, so a value only decrypts
+ * under the key it was encrypted for. keys come from a pluggable KeyProvider, KeyFile reads them from a local file.
+ */
+package crypt
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Algorithm 加密算法的名称
+const Algorithm = "AES256_GCM"
+
+const (
+    prefix = "ENC[" + Algorithm + ","
+    suffix = "]"
+)
+
+// KeyProvider 提供加密以及解密使用的256位密钥
+type KeyProvider interface {
+    // EncryptionKey 获取加密使用的密钥以及其标识，标识会记录在密文中
+    EncryptionKey() (id string, key []byte, err error)
+    // DecryptionKey 获取标识对应的密钥，不存在时返回错误
+    DecryptionKey(id string) ([]byte, error)
+}
+
+// IsEncrypted 判断字符串是否是加密的值
+func IsEncrypted(value string) bool {
+    return strings.HasPrefix(value, "ENC[") && strings.HasSuffix(value, suffix)
+}
+
+// Encrypt 加密toml格式的值，如 "secret"、8080，返回 ENC[...] 形式的字符串
+// path为值的键路径，由键名组成，不包含数组下标，解密时必须使用相同的路径
+func Encrypt(literal string, path []string, keys KeyProvider) (string, error) {
+    if _, err := parser.ParseSingle(literal); err != nil {
+        return "", err
+    }
+    id, key, err := keys.EncryptionKey()
+    if err != nil {
+        return "", err
+    }
+    if strings.ContainsAny(id, ",]") {
+        return "", fmt.Errorf("invalid key id %q", id)
+    }
+    aead, err := newAEAD(key)
+    if err != nil {
+        return "", err
+    }
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+        return "", err
+    }
+    data := aead.Seal(nonce, nonce, []byte(literal), additionalData(path))
+    return prefix + "key:" + id + ",data:" + base64.StdEncoding.EncodeToString(data) + suffix, nil
+}
+
+// Decrypt 解密 ENC[...] 形式的字符串，返回toml格式的值的原文；value不是加密的值时ok为false
+// path为值的键路径，与加密时的路径不同时解密失败
+func Decrypt(value string, path []string, keys KeyProvider) (literal string, ok bool, err error) {
+    if !IsEncrypted(value) {
+        return "", false, nil
+    }
+    if !strings.HasPrefix(value, prefix) {
+        return "", true, errors.New("unsupported encryption, expected " + Algorithm)
+    }
+    id, data := "", ""
+    for _, field := range strings.Split(value[len(prefix):len(value)-len(suffix)], ",") {
+        switch {
+        case strings.HasPrefix(field, "key:"):
+            id = field[len("key:"):]
+        case strings.HasPrefix(field, "data:"):
+            data = field[len("data:"):]
+        default:
+            return "", true, fmt.Errorf("invalid encrypted value field %q", field)
+        }
+    }
+    raw, err := base64.StdEncoding.DecodeString(data)
+    if err != nil || data == "" {
+        return "", true, errors.New("invalid encrypted data")
+    }
+    key, err := keys.DecryptionKey(id)
+    if err != nil {
+        return "", true, err
+    }
+    aead, err := newAEAD(key)
+    if err != nil {
+        return "", true, err
+    }
+    if len(raw) < aead.NonceSize() {
+        return "", true, errors.New("invalid encrypted data")
+    }
+    plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], additionalData(path))
+    if err != nil {
+        return "", true, fmt.Errorf("decrypt with key %s failed: %s", id, err)
+    }
+    return string(plain), true, nil
+}
+
+// DecryptValue 解密并解析加密的值，value不是加密的值时返回nil
+func DecryptValue(value string, path []string, keys KeyProvider) (*xtype.Object, error) {
+    literal, ok, err := Decrypt(value, path, keys)
+    if !ok || err != nil {
+        return nil, err
+    }
+    obj, err := parser.ParseSingle(literal)
+    if err != nil {
+        return nil, errors.New("decrypted value is not a valid toml value")
+    }
+    return obj, nil
+}
+
+// Decrypter 生成解析时使用的解密函数，见parser.Options.Decrypt
+func Decrypter(keys KeyProvider) func(path []string, value string) (string, bool, error) {
+    return func(path []string, value string) (string, bool, error) {
+        return Decrypt(value, path, keys)
+    }
+}
+
+// additionalData 认证的附加数据，为toml格式的键路径，如 db."api.key"
+func additionalData(path []string) []byte {
+    names := make([]string, len(path))
+    for i, name := range path {
+        names[i] = formatter.FmtTomlKey(name)
+    }
+    return []byte(strings.Join(names, "."))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+    if len(key) != 32 {
+        return nil, fmt.Errorf("invalid key size %d, expected 32 bytes", len(key))
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
@@ -0,0 +1,265 @@
+package crypt
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/whencome/toml2x/parser"
+	"github.com/whencome/toml2x/redact"
+)
+
+// testKeys 测试使用的固定密钥
+type testKeys map[string][]byte
+
+func (k testKeys) EncryptionKey() (string, []byte, error) {
+	return "test", k["test"], nil
+}
+
+func (k testKeys) DecryptionKey(id string) ([]byte, error) {
+	if key, ok := k[id]; ok {
+		return key, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func newTestKeys(seed byte) testKeys {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = seed + byte(i)
+	}
+	return testKeys{"test": key}
+}
+
+func TestEncrypt(t *testing.T) {
+	keys := newTestKeys(1)
+	path := []string{"db", "password"}
+	values := []string{`"hunter2"`, `'c:\path'`, `8080`, `true`, `1979-05-27T07:32:00Z`, "\"\"\"\nline 1\nline 2\"\"\"", `{ user = "root", pass = "x" }`, `[1, 2]`}
+	for _, v := range values {
+		enc, err := Encrypt(v, path, keys)
+		if err != nil {
+			t.Fatalf("encrypt %s failed: %s\n", v, err)
+		}
+		if !strings.HasPrefix(enc, "ENC[AES256_GCM,key:test,data:") || !IsEncrypted(enc) || strings.Contains(enc, v) {
+			t.Logf("unexpected encrypted value %s\n", enc)
+			t.Fail()
+		}
+		literal, ok, err := Decrypt(enc, path, keys)
+		if !ok || err != nil || literal != v {
+			t.Logf("decrypt %s: expected %s, got %q %v %v\n", enc, v, literal, ok, err)
+			t.Fail()
+		}
+		expected, _ := parser.ParseSingle(v)
+		obj, err := DecryptValue(enc, path, keys)
+		if err != nil || obj.Type != expected.Type || obj.Json(false) != expected.Json(false) {
+			t.Logf("decrypt value %s: expected %s, got %v %v\n", v, expected.Json(false), obj, err)
+			t.Fail()
+		}
+	}
+	if _, err := Encrypt("not a value", path, keys); err == nil {
+		t.Logf("expected error encrypting an invalid literal\n")
+		t.Fail()
+	}
+
+	enc, _ := Encrypt(`"hunter2"`, path, keys)
+	messages := map[string]string{
+		"wrong key":   "decrypt with key test failed: cipher: message authentication failed",
+		"moved":       "decrypt with key test failed: cipher: message authentication failed",
+		"tampered":    "decrypt with key test failed: cipher: message authentication failed",
+		"unknown key": "file does not exist",
+		"algorithm":   "unsupported encryption, expected AES256_GCM",
+		"data":        "invalid encrypted data",
+	}
+	cases := map[string]struct {
+		value string
+		path  []string
+		keys  KeyProvider
+	}{
+		"wrong key":   {enc, path, newTestKeys(2)},
+		"moved":       {enc, []string{"db", "user"}, keys},
+		"tampered":    {strings.Replace(enc, "data:", "data:AAAA", 1), path, keys},
+		"unknown key": {strings.Replace(enc, "key:test", "key:other", 1), path, keys},
+		"algorithm":   {strings.Replace(enc, "AES256_GCM", "PGP", 1), path, keys},
+		"data":        {"ENC[AES256_GCM,key:test,data:!]", path, keys},
+	}
+	for name, c := range cases {
+		if _, ok, err := Decrypt(c.value, c.path, c.keys); !ok || err == nil || err.Error() != messages[name] {
+			t.Logf("%s: expected %s, got %v\n", name, messages[name], err)
+			t.Fail()
+		}
+	}
+	if _, ok, err := Decrypt("ENC is not here", path, keys); ok || err != nil {
+		t.Logf("expected plain strings to be left alone, got %v %v\n", ok, err)
+		t.Fail()
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	newKey, _ := GenerateKey()
+	oldKey := hex.EncodeToString(newTestKeys(1)["test"])
+	path := filepath.Join(dir, "keys")
+	ioutil.WriteFile(path, []byte("# rotated keys\n"+newKey+"\n\n"+oldKey+"\n"), 0600)
+	kf, err := NewKeyFile(path)
+	if err != nil {
+		t.Fatalf("load key file failed: %s\n", err)
+	}
+	// 使用旧密钥加密的值仍然可以解密，新的值使用第一个密钥加密
+	old, _ := Encrypt(`"old"`, nil, newTestKeys(1))
+	old = strings.Replace(old, "key:test", "key:"+KeyID(newTestKeys(1)["test"]), 1)
+	if literal, _, err := Decrypt(old, nil, kf); err != nil || literal != `"old"` {
+		t.Logf("decrypt with the old key: got %q %v\n", literal, err)
+		t.Fail()
+	}
+	enc, _ := Encrypt(`"new"`, nil, kf)
+	if id, _, _ := kf.EncryptionKey(); id == KeyID(newTestKeys(1)["test"]) || !strings.Contains(enc, "key:"+id+",") {
+		t.Logf("expected the first key to encrypt, got %s\n", enc)
+		t.Fail()
+	}
+
+	invalid := map[string]string{
+		"short":   "c2hvcnQ=\n",
+		"comment": "# no keys\n",
+	}
+	expected := map[string]string{
+		"short":   ":1: invalid key, expected 32 bytes encoded in base64 or hex",
+		"comment": ": no keys found",
+	}
+	for name, content := range invalid {
+		ioutil.WriteFile(path, []byte(content), 0600)
+		if _, err := NewKeyFile(path); err == nil || err.Error() != path+expected[name] {
+			t.Logf("%s: expected %s, got %v\n", name, path+expected[name], err)
+			t.Fail()
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	keys := newTestKeys(3)
+	doc := `# app config
+name = "app"
+password = "hunter2"   # keep me
+
+[db]
+port = 5432
+secret_port = 5433
+[[users]]
+name = "bob"
+token = """
+multi
+line"""
+`
+	match := func(names []string) bool {
+		path := strings.Join(names, ".")
+		return strings.Contains(path, "password") || strings.Contains(path, "secret") || path == "users.token"
+	}
+	enc, err := EncryptDocument(doc, match, keys)
+	if err != nil {
+		t.Fatalf("encrypt document failed: %s\n", err)
+	}
+	lines := strings.Split(enc, "\n")
+	if !strings.HasPrefix(lines[2], `password = "ENC[AES256_GCM,`) || !strings.HasSuffix(lines[2], `]"   # keep me`) ||
+		!strings.HasPrefix(lines[6], `secret_port = "ENC[`) || !strings.HasPrefix(lines[9], `token = "ENC[`) || lines[5] != "port = 5432" {
+		t.Logf("unexpected encrypted document\n%s\n", enc)
+		t.Fail()
+	}
+	// 再次加密时已经加密的值保持不变
+	if again, err := EncryptDocument(enc, match, keys); err != nil || again != enc {
+		t.Logf("encrypting twice changed the document: %v\n", err)
+		t.Fail()
+	}
+
+	expected, _ := parser.ParseTable(doc)
+	obj, err := parser.ParseWithOptions("table", enc, &parser.Options{Decrypt: Decrypter(keys)})
+	if err != nil || obj.Json(false) != expected.Json(false) {
+		t.Logf("parse encrypted document: expected %s, got %v %v\n", expected.Json(false), obj, err)
+		t.Fail()
+	}
+	if dec, err := DecryptDocument(enc, nil, keys); err != nil || dec != doc {
+		t.Logf("decrypt document: expected\n%s\ngot\n%s %v\n", doc, dec, err)
+		t.Fail()
+	}
+
+	_, err = parser.ParseWithOptions("table", enc, &parser.Options{File: "app.toml", Decrypt: Decrypter(newTestKeys(4))})
+	if err == nil || err.Error() != "app.toml:3:12: decrypt with key test failed: cipher: message authentication failed" {
+		t.Logf("expected decryption error with position, got %v\n", err)
+		t.Fail()
+	}
+	if _, err := DecryptDocument(enc, nil, newTestKeys(4)); err == nil || !strings.HasPrefix(err.Error(), "password: decrypt with key test failed") {
+		t.Logf("expected decryption error with key path, got %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestDocumentInline(t *testing.T) {
+	keys := newTestKeys(5)
+	doc := `db = { host = "localhost", password = "hunter2" }   # inline
+creds = { token = "abc", nested = { api_key = 'k1', port = 1 } }
+tokens = ["t1", "t2"]
+users = [ { name = "bob", password = "p1" },
+  { name = "amy", password = "p2" } ]
+`
+	match := (&redact.Options{}).Match
+	enc, err := EncryptDocument(doc, match, keys)
+	if err != nil {
+		t.Fatalf("encrypt document failed: %s\n", err)
+	}
+	for _, plain := range []string{`"hunter2"`, `"abc"`, `'k1'`, `"t1"`, `"p1"`, `"p2"`} {
+		if strings.Contains(enc, plain) {
+			t.Logf("secret %s left in the encrypted document\n%s\n", plain, enc)
+			t.Fail()
+		}
+	}
+	lines := strings.Split(enc, "\n")
+	if !strings.HasPrefix(lines[0], `db = { host = "localhost", password = "ENC[AES256_GCM,`) || !strings.HasSuffix(lines[0], `]" }   # inline`) ||
+		!strings.HasPrefix(lines[1], `creds = { token = "ENC[`) || !strings.Contains(lines[1], `, port = 1 } }`) ||
+		!strings.HasPrefix(lines[2], `tokens = "ENC[`) || !strings.HasPrefix(lines[3], `users = [ { name = "bob", password = "ENC[`) ||
+		!strings.HasPrefix(lines[4], `  { name = "amy", password = "ENC[`) {
+		t.Logf("unexpected encrypted document\n%s\n", enc)
+		t.Fail()
+	}
+	if again, err := EncryptDocument(enc, match, keys); err != nil || again != enc {
+		t.Logf("encrypting twice changed the document: %v\n", err)
+		t.Fail()
+	}
+
+	expected, _ := parser.ParseTable(doc)
+	obj, err := parser.ParseWithOptions("table", enc, &parser.Options{Decrypt: Decrypter(keys)})
+	if err != nil || obj.Json(false) != expected.Json(false) {
+		t.Logf("parse encrypted document: expected %s, got %v %v\n", expected.Json(false), obj, err)
+		t.Fail()
+	}
+	if dec, err := DecryptDocument(enc, nil, keys); err != nil || dec != doc {
+		t.Logf("decrypt document: expected\n%s\ngot\n%s %v\n", doc, dec, err)
+		t.Fail()
+	}
+	if dec, err := DecryptDocument(enc, func(names []string) bool { return names[0] == "users" }, keys); err != nil ||
+		!strings.Contains(dec, `password = "p1"`) || strings.Contains(dec, "hunter2") {
+		t.Logf("decrypt selected values: got\n%s %v\n", dec, err)
+		t.Fail()
+	}
+
+	// 密文只能在加密时的键路径下解密
+	secret, _ := Encrypt(`"x"`, []string{"db", "password"}, keys)
+	for doc, ok := range map[string]bool{
+		"[db]\npassword = " + strconv.Quote(secret):         true,
+		"db = { password = " + strconv.Quote(secret) + " }": true,
+		"db.password = " + strconv.Quote(secret):            true,
+		"[api]\npassword = " + strconv.Quote(secret):        false,
+		"db = { token = " + strconv.Quote(secret) + " }":    false,
+	} {
+		obj, err := parser.ParseWithOptions("table", doc, &parser.Options{Decrypt: Decrypter(keys)})
+		if ok && (err != nil || !strings.Contains(obj.Json(false), `"x"`)) || !ok && err == nil {
+			t.Logf("parse %q: expected success %v, got %v\n", doc, ok, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package crypt
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/cst"
+    "github.com/whencome/toml2x/lexer"
+    "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// EncryptDocument 原样加密文档中选中的键的值，注释、格式以及其他内容保持不变
+// match 判断键路径是否需要加密，路径由键名组成，不包含数组下标（与redact.Options.Match一致）；
+// 已经加密的值保持不变，表头所在的表不会被加密，选中的行内表以及数组作为整体加密，
+// 否则查找其中选中的值，如 db = { password = "x" } 中的db.password
+func EncryptDocument(toml string, match func(keys []string) bool, keys KeyProvider) (string, error) {
+    if match == nil {
+        return "", errors.New("no keys selected for encryption")
+    }
+    return transform(toml, match, false, func(names []string, literal string, value *xtype.Object) (string, error) {
+        if value.Type == xtype.TypeString && IsEncrypted(util.String(value.Value)) {
+            return literal, nil
+        }
+        enc, err := Encrypt(literal, names, keys)
+        if err != nil {
+            return "", err
+        }
+        return strconv.Quote(enc), nil
+    })
+}
+
+// DecryptDocument 原样解密文档中的值，包括行内表以及数组中的值，恢复加密前的原文，match为nil时解密所有的值
+func DecryptDocument(toml string, match func(keys []string) bool, keys KeyProvider) (string, error) {
+    return transform(toml, match, true, func(names []string, literal string, value *xtype.Object) (string, error) {
+        if value.Type != xtype.TypeString {
+            return literal, nil
+        }
+        plain, ok, err := Decrypt(util.String(value.Value), names, keys)
+        if err != nil || !ok {
+            return literal, err
+        }
+        return plain, nil
+    })
+}
+
+// transform 依次处理文档中选中的值，fn返回值的新的原文
+// leaves为true时只处理行内表以及数组中的单个值，选中的行内表以及数组中的值都是选中的
+func transform(toml string, match func(keys []string) bool, leaves bool, fn func(names []string, literal string, value *xtype.Object) (string, error)) (string, error) {
+    doc, err := cst.Parse(toml)
+    if err != nil {
+        return "", err
+    }
+    var header []string
+    for _, n := range doc.Nodes {
+        switch n.Type {
+        case cst.NodeTable, cst.NodeArrayTable:
+            header = n.Key
+            continue
+        case cst.NodeKeyValue:
+        default:
+            continue
+        }
+        names := append(header[:len(header):len(header)], n.Key...)
+        if _, err := parser.ParseSingle(n.Value); err != nil {
+            return "", errors.New(strings.Join(names, ".") + ": " + err.Error())
+        }
+        s := &scanner{lex: lexer.New("", n.Value), match: match, leaves: leaves}
+        s.value(s.next(lexer.ModeValue), names, false)
+        // 从后向前替换，前面的值的位置保持不变
+        value := n.Value
+        for i := len(s.found) - 1; i >= 0; i-- {
+            f := s.found[i]
+            literal := value[f.start:f.end]
+            obj, err := parser.ParseSingle(literal)
+            if err == nil {
+                literal, err = fn(f.names, literal, obj)
+            }
+            if err != nil {
+                return "", errors.New(strings.Join(f.names, ".") + ": " + err.Error())
+            }
+            value = value[:f.start] + literal + value[f.end:]
+        }
+        n.Value = value
+    }
+    return doc.String(), nil
+}
+
+// selected 选中的值在原文中的位置
+type selected struct {
+    names      []string
+    start, end int
+}
+
+// scanner 在值的原文中查找选中的值，原文必须是有效的值
+type scanner struct {
+    lex    *lexer.Lexer
+    match  func(keys []string) bool
+    leaves bool
+    found  []selected
+}
+
+// next 读取下一个记号，跳过换行
+func (s *scanner) next(mode int) lexer.Token {
+    t := s.lex.Next(mode)
+    for t.Type == lexer.Newline {
+        t = s.lex.Next(mode)
+    }
+    return t
+}
+
+// value 读取从记号t开始的值，names为值的键路径，parent为true时上级的值已经选中，返回值的结束位置
+func (s *scanner) value(t lexer.Token, names []string, parent bool) int {
+    start := t.Offset
+    ok := parent || s.match == nil || s.match(names)
+    if t.Type != lexer.LeftBracket && t.Type != lexer.LeftBrace || ok && !s.leaves {
+        end := start + len(t.Raw)
+        if t.Type == lexer.LeftBracket || t.Type == lexer.LeftBrace {
+            end = s.skip(t)
+        }
+        if ok {
+            s.found = append(s.found, selected{names: names, start: start, end: end})
+        }
+        return end
+    }
+    if t.Type == lexer.LeftBracket {
+        for {
+            if t = s.next(lexer.ModeValue); t.Type == lexer.RightBracket {
+                return t.Offset + len(t.Raw)
+            }
+            s.value(t, names, ok)
+            if t = s.next(lexer.ModeValue); t.Type == lexer.RightBracket {
+                return t.Offset + len(t.Raw)
+            }
+        }
+    }
+    for {
+        if t = s.next(lexer.ModeKey); t.Type == lexer.RightBrace {
+            return t.Offset + len(t.Raw)
+        }
+        child := append(names[:len(names):len(names)], t.Value)
+        for t = s.next(lexer.ModeKey); t.Type == lexer.Dot; t = s.next(lexer.ModeKey) {
+            child = append(child, s.next(lexer.ModeKey).Value)
+        }
+        s.value(s.next(lexer.ModeValue), child, ok)
+        if t = s.next(lexer.ModeKey); t.Type == lexer.RightBrace {
+            return t.Offset + len(t.Raw)
+        }
+    }
+}
+
+// skip 跳过从记号t开始的数组或者行内表，返回其结束位置
+func (s *scanner) skip(t lexer.Token) int {
+    depth := 0
+    for {
+        switch t.Type {
+        case lexer.LeftBracket, lexer.LeftBrace:
+            depth++
+        case lexer.RightBracket, lexer.RightBrace:
+            depth--
+        case lexer.EOF:
+            return t.Offset
+        }
+        if depth == 0 {
+            return t.Offset + len(t.Raw)
+        }
+        t = s.lex.Next(lexer.ModeValue)
+    }
+}
@@ -0,0 +1,92 @@
+package crypt
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strings"
+)
+
+// KeyFile 从本地文件读取密钥
+// 文件中每行一个base64或者hex编码的256位密钥，空行以及#开头的行被忽略；
+// 第一个密钥用于加密，所有密钥都可以用于解密，轮换密钥时将新密钥加在第一行即可
+type KeyFile struct {
+    Path string
+    ids  []string
+    keys map[string][]byte
+}
+
+// NewKeyFile 读取密钥文件
+func NewKeyFile(path string) (*KeyFile, error) {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    kf := &KeyFile{Path: path, keys: make(map[string][]byte)}
+    for i, line := range strings.Split(string(content), "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        key, err := decodeKey(line)
+        if err != nil {
+            return nil, fmt.Errorf("%s:%d: %s", path, i+1, err)
+        }
+        id := KeyID(key)
+        if _, ok := kf.keys[id]; !ok {
+            kf.ids = append(kf.ids, id)
+            kf.keys[id] = key
+        }
+    }
+    if len(kf.ids) == 0 {
+        return nil, errors.New(path + ": no keys found")
+    }
+    return kf, nil
+}
+
+// EncryptionKey 获取第一个密钥
+func (kf *KeyFile) EncryptionKey() (string, []byte, error) {
+    id := kf.ids[0]
+    return id, kf.keys[id], nil
+}
+
+// DecryptionKey 获取标识对应的密钥
+func (kf *KeyFile) DecryptionKey(id string) ([]byte, error) {
+    if key, ok := kf.keys[id]; ok {
+        return key, nil
+    }
+    return nil, fmt.Errorf("key %s not found in %s", id, kf.Path)
+}
+
+// KeyID 密钥的标识，为密钥的sha256哈希的前8位
+func KeyID(key []byte) string {
+    sum := sha256.Sum256(key)
+    return hex.EncodeToString(sum[:4])
+}
+
+// GenerateKey 生成随机的256位密钥，返回其base64编码，可以直接写入密钥文件
+func GenerateKey() (string, error) {
+    key := make([]byte, 32)
+    if _, err := io.ReadFull(rand.Reader, key); err != nil {
+        return "", err
+    }
+    return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func decodeKey(s string) ([]byte, error) {
+    if len(s) == 64 {
+        if key, err := hex.DecodeString(s); err == nil {
+            return key, nil
+        }
+    }
+    key, err := base64.StdEncoding.DecodeString(s)
+    if err != nil || len(key) != 32 {
+        return nil, errors.New("invalid key, expected 32 bytes encoded in base64 or hex")
+    }
+    return key, nil
+}
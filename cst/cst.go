@@ -0,0 +1,273 @@
+/**
+ * lossless concrete syntax tree of toml documents.
+ * comments, blank lines, key order and the original spelling of literals are all kept,
+ * so a document can be edited and written back with untouched regions byte-identical.
+ */
+package cst
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/whencome/toml2x/lexer"
+    "github.com/whencome/toml2x/parser"
+)
+
+// define node types
+const (
+    NodeBlank      = iota // 空行
+    NodeComment           // 注释行
+    NodeTable             // [table]
+    NodeArrayTable        // [[table]]
+    NodeKeyValue          // key = value
+)
+
+// Node 文档中的一行（多行值占据多行）
+// 各部分依次拼接即为原文
+type Node struct {
+    Type     int
+    Indent   string   // 行首空白
+    Key      []string // 解析后的键名或表名
+    KeyRaw   string   // 键名原文，表头为括号内的内容
+    Sep      string   // 键与值之间的内容，包括等号及两侧空白
+    Value    string   // 值的原文
+    Trailing string   // 行尾空白以及注释
+    Newline  string   // 换行符，文档最后一行可能为空
+}
+
+// Document 定义toml文档
+type Document struct {
+    Nodes []*Node
+}
+
+// String 输出节点原文
+func (n *Node) String() string {
+    switch n.Type {
+    case NodeTable:
+        return n.Indent + "[" + n.KeyRaw + "]" + n.Trailing + n.Newline
+    case NodeArrayTable:
+        return n.Indent + "[[" + n.KeyRaw + "]]" + n.Trailing + n.Newline
+    case NodeKeyValue:
+        return n.Indent + n.KeyRaw + n.Sep + n.Value + n.Trailing + n.Newline
+    }
+    return n.Indent + n.Trailing + n.Newline
+}
+
+// String 输出文档内容，未修改的部分与原文完全一致
+func (d *Document) String() string {
+    buf := bytes.Buffer{}
+    for _, n := range d.Nodes {
+        buf.WriteString(n.String())
+    }
+    return buf.String()
+}
+
+// Parse 解析toml文档
+func Parse(src string) (*Document, error) {
+    doc := &Document{Nodes: make([]*Node, 0)}
+    pos := 0
+    line := 1
+    size := len(src)
+    for pos < size {
+        start := pos
+        n := &Node{}
+        // 行首空白
+        for pos < size && (src[pos] == ' ' || src[pos] == '\t') {
+            pos++
+        }
+        n.Indent = src[start:pos]
+
+        var err error
+        switch {
+        case pos >= size || src[pos] == '\n' || src[pos] == '\r':
+            n.Type = NodeBlank
+        case src[pos] == '#':
+            n.Type = NodeComment
+        case src[pos] == '[':
+            pos, err = parseHeader(src, pos, n)
+        default:
+            pos, err = parseKeyValue(src, pos, n)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %s", line, err)
+        }
+
+        // 行尾空白及注释
+        tStart := pos
+        for pos < size && (src[pos] == ' ' || src[pos] == '\t') {
+            pos++
+        }
+        if pos < size && src[pos] == '#' {
+            for pos < size && src[pos] != '\n' && !strings.HasPrefix(src[pos:], "\r\n") {
+                pos++
+            }
+        }
+        n.Trailing = src[tStart:pos]
+        if pos < size {
+            if strings.HasPrefix(src[pos:], "\r\n") {
+                n.Newline = "\r\n"
+            } else if src[pos] == '\n' {
+                n.Newline = "\n"
+            } else {
+                return nil, fmt.Errorf("line %d: unexpected content after %q: %q", line, strings.TrimSpace(src[start:pos]), firstLine(src[pos:]))
+            }
+            pos += len(n.Newline)
+        }
+        line += strings.Count(src[start:pos], "\n")
+        doc.Nodes = append(doc.Nodes, n)
+    }
+    return doc, nil
+}
+
+// parseHeader 解析表头
+func parseHeader(src string, pos int, n *Node) (int, error) {
+    open, close := "[", "]"
+    n.Type = NodeTable
+    if strings.HasPrefix(src[pos:], "[[") {
+        open, close = "[[", "]]"
+        n.Type = NodeArrayTable
+    }
+    pos += len(open)
+    start := pos
+    key, end, err := scanKey(src, pos)
+    if err != nil {
+        return pos, err
+    }
+    for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+        end++
+    }
+    if !strings.HasPrefix(src[end:], close) {
+        return end, errors.New("missing closing delimiter of table header")
+    }
+    n.KeyRaw = src[start:end]
+    n.Key = key
+    return end + len(close), nil
+}
+
+// parseKeyValue 解析键值对
+func parseKeyValue(src string, pos int, n *Node) (int, error) {
+    n.Type = NodeKeyValue
+    key, keyEnd, err := scanKey(src, pos)
+    if err != nil {
+        return pos, err
+    }
+    n.KeyRaw = src[pos:keyEnd]
+    n.Key = key
+
+    // 键名后的空白归入分隔符
+    pos = keyEnd
+    for pos < len(src) && (src[pos] == ' ' || src[pos] == '\t') {
+        pos++
+    }
+    if pos >= len(src) || src[pos] != '=' {
+        return pos, errors.New("missing '=' after key")
+    }
+    pos++
+    for pos < len(src) && (src[pos] == ' ' || src[pos] == '\t') {
+        pos++
+    }
+    n.Sep = src[keyEnd:pos]
+
+    end, err := valueEnd(src, pos)
+    if err != nil {
+        return pos, err
+    }
+    if end == pos {
+        return pos, errors.New("missing value of key " + n.KeyRaw)
+    }
+    n.Value = src[pos:end]
+    return end, nil
+}
+
+// scanKey 读取从pos开始的点号分隔的键名，返回各级键名以及键名的结束位置
+func scanKey(src string, pos int) ([]string, int, error) {
+    lex := lexer.New("", src[pos:])
+    keys := make([]string, 0, 1)
+    for {
+        t := lex.Next(lexer.ModeKey)
+        switch t.Type {
+        case lexer.Bare, lexer.BasicString, lexer.LiteralString:
+        case lexer.Error:
+            return nil, pos, errors.New(t.Value)
+        default:
+            return nil, pos, fmt.Errorf("expected key, got %s", t)
+        }
+        keys = append(keys, t.Value)
+        if lex.Peek(lexer.ModeKey).Type != lexer.Dot {
+            return keys, pos + t.Offset + len(t.Raw), nil
+        }
+        lex.Next(lexer.ModeKey)
+    }
+}
+
+// valueEnd 查找从pos开始的值的结束位置，数组和行内表中可以包含换行、注释以及嵌套的值
+func valueEnd(src string, pos int) (int, error) {
+    lex := lexer.New("", src[pos:])
+    depth := 0
+    for {
+        t := lex.Next(lexer.ModeValue)
+        switch t.Type {
+        case lexer.Error:
+            return pos, errors.New(t.Value)
+        case lexer.EOF:
+            if depth > 0 {
+                return pos, errors.New("missing closing delimiter of array or inline table")
+            }
+            return pos, nil
+        case lexer.Newline:
+            if depth == 0 {
+                return pos, nil
+            }
+            continue
+        case lexer.LeftBracket, lexer.LeftBrace:
+            depth++
+            continue
+        case lexer.RightBracket, lexer.RightBrace:
+            depth--
+            if depth < 0 {
+                return pos, fmt.Errorf("unexpected %s", t)
+            }
+        default:
+            if depth == 0 && !t.IsString() && t.Type != lexer.Bare {
+                return pos, fmt.Errorf("unexpected %s", t)
+            }
+        }
+        if depth == 0 {
+            return pos + t.Offset + len(t.Raw), nil
+        }
+    }
+}
+
+// splitKey 将键名按点号拆分，并去除引号
+func splitKey(raw string) ([]string, error) {
+    keys, end, err := scanKey(raw, 0)
+    if err != nil {
+        return nil, err
+    }
+    if strings.TrimSpace(raw[end:]) != "" {
+        return nil, errors.New("invalid key: " + raw)
+    }
+    return keys, nil
+}
+
+func firstLine(s string) string {
+    if i := strings.IndexByte(s, '\n'); i >= 0 {
+        return s[:i]
+    }
+    return s
+}
+
+// ensure the literal is a valid toml value
+func checkValue(value string) error {
+    end, err := valueEnd(value, 0)
+    if err != nil {
+        return err
+    }
+    if end != len(value) {
+        return errors.New("invalid value: " + value)
+    }
+    _, err = parser.ParseSingle(value)
+    return err
+}
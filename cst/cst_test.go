@@ -0,0 +1,112 @@
+package cst
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tomlBytes, err := ioutil.ReadFile("../example.toml")
+	if err != nil {
+		t.Logf("read file content failed: %s\n", err)
+		t.Fail()
+		return
+	}
+	toml := string(tomlBytes)
+	doc, err := Parse(toml)
+	if err != nil {
+		t.Logf("parse document failed: %s\n", err)
+		t.Fail()
+		return
+	}
+	if doc.String() != toml {
+		t.Log("document is not byte-identical after round trip\n")
+		t.Fail()
+	}
+
+	crlf := "a = 1 # one\r\n\r\n[b]\r\nc = [\r\n  1, # first\r\n  2,\r\n]"
+	doc, err = Parse(crlf)
+	if err != nil || doc.String() != crlf {
+		t.Logf("crlf document is not byte-identical after round trip: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestEdit(t *testing.T) {
+	toml := `# service config
+name = "demo"  # service name
+debug = true
+
+[database]
+  host = "localhost"
+  port = 5432 # default port
+
+[[servers]]
+ip = "10.0.0.1"
+
+[[servers]]
+ip = "10.0.0.2"
+`
+	expected := `# service config
+name = "demo"  # service name
+
+[database]
+  host = "db.internal"
+  port = 5432 # default port
+  user = "admin"
+
+[[servers]]
+ip = "10.0.0.1"
+
+[[servers]]
+ip = "10.0.0.3"
+
+[cache]
+size = 128
+`
+	doc, err := Parse(toml)
+	if err != nil {
+		t.Logf("parse document failed: %s\n", err)
+		t.Fail()
+		return
+	}
+	if v, ok := doc.Get("database.port"); !ok || v != "5432" {
+		t.Logf("unexpected value of database.port: %s\n", v)
+		t.Fail()
+	}
+	steps := []error{
+		doc.Set("database.host", `"db.internal"`),
+		doc.Set("database.user", `"admin"`),
+		doc.Set("servers.1.ip", `"10.0.0.3"`),
+		doc.Delete("debug"),
+		doc.InsertTable("cache", false),
+		doc.Set("cache.size", "128"),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Logf("edit step %d failed: %s\n", i, err)
+			t.Fail()
+		}
+	}
+	if doc.String() != expected {
+		t.Logf("unexpected document:\n%s\n", doc.String())
+		t.Fail()
+	}
+
+	// 非法的值
+	if err := doc.Set("name", `"demo" # comment`); err == nil {
+		t.Log("invalid value should be rejected\n")
+		t.Fail()
+	}
+	if err := doc.Delete("missing.key"); err == nil {
+		t.Log("deleting a missing key should fail\n")
+		t.Fail()
+	}
+
+	for path, expected := range map[string]bool{"database": true, "servers.1": true, "servers.2": false, "servers": false, "cache": true, "name": false} {
+		if doc.HasTable(path) != expected {
+			t.Logf("has table %s: expected %v\n", path, expected)
+			t.Fail()
+		}
+	}
+}
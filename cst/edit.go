@@ -0,0 +1,229 @@
+package cst
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/formatter"
+)
+
+// section 文档中的一个表，包括表头以及其后直到下一个表头之间的节点
+type section struct {
+    path   []string // 完整路径，表数组的元素带有下标
+    header int      // 表头节点的位置，根表为-1
+    end    int      // 下一个表头节点的位置
+}
+
+// Get 获取给定路径的值的原文
+// path 以点号分隔的路径，表数组元素使用下标，如 fruit.0.name
+func (d *Document) Get(path string) (string, bool) {
+    keys, err := splitKey(path)
+    if err != nil {
+        return "", false
+    }
+    i := d.lookup(keys)
+    if i < 0 {
+        return "", false
+    }
+    return d.Nodes[i].Value, true
+}
+
+// Set 设置给定路径的值，value为toml格式的值
+// 键已存在时仅替换值的原文，否则在最接近的表中新增键值对
+func (d *Document) Set(path string, value string) error {
+    keys, err := splitKey(path)
+    if err != nil {
+        return err
+    }
+    if err := checkValue(value); err != nil {
+        return err
+    }
+    if i := d.lookup(keys); i >= 0 {
+        d.Nodes[i].Value = value
+        return nil
+    }
+
+    // 找到路径最长的上级表
+    var dst *section
+    for _, sec := range d.sections() {
+        if len(sec.path) < len(keys) && hasPrefix(keys, sec.path) && (dst == nil || len(sec.path) > len(dst.path)) {
+            dst = sec
+        }
+    }
+    rest := keys[len(dst.path):]
+    n := &Node{
+        Type:    NodeKeyValue,
+        Key:     rest,
+        KeyRaw:  formatKey(rest),
+        Sep:     " = ",
+        Value:   value,
+        Newline: d.newline(),
+    }
+    pos := dst.header + 1
+    if dst.header >= 0 {
+        n.Indent = d.Nodes[dst.header].Indent
+    }
+    for i := dst.header + 1; i < dst.end; i++ {
+        if d.Nodes[i].Type == NodeKeyValue {
+            pos = i + 1
+            n.Indent = d.Nodes[i].Indent
+        }
+    }
+    d.insert(pos, n)
+    return nil
+}
+
+// HasTable 判断文档中是否存在以表头定义的表，表数组元素使用下标，如 fruit.0
+func (d *Document) HasTable(path string) bool {
+    keys, err := splitKey(path)
+    if err != nil {
+        return false
+    }
+    for _, sec := range d.sections() {
+        if sec.header >= 0 && equalPath(sec.path, keys) {
+            return true
+        }
+    }
+    return false
+}
+
+// Delete 删除给定路径的键值对或者表
+func (d *Document) Delete(path string) error {
+    keys, err := splitKey(path)
+    if err != nil {
+        return err
+    }
+    if i := d.lookup(keys); i >= 0 {
+        d.Nodes = append(d.Nodes[:i], d.Nodes[i+1:]...)
+        return nil
+    }
+    for _, sec := range d.sections() {
+        if sec.header >= 0 && equalPath(sec.path, keys) {
+            d.Nodes = append(d.Nodes[:sec.header], d.Nodes[sec.end:]...)
+            return nil
+        }
+    }
+    return errors.New("key not found: " + path)
+}
+
+// InsertTable 在文档末尾添加表，array为true时添加表数组的元素
+func (d *Document) InsertTable(path string, array bool) error {
+    keys, err := splitKey(path)
+    if err != nil {
+        return err
+    }
+    n := &Node{
+        Type:    NodeTable,
+        Key:     keys,
+        KeyRaw:  formatKey(keys),
+        Newline: d.newline(),
+    }
+    if array {
+        n.Type = NodeArrayTable
+    } else {
+        for _, sec := range d.sections() {
+            if equalPath(sec.path, keys) {
+                return errors.New("table already exists: " + path)
+            }
+        }
+    }
+    size := len(d.Nodes)
+    if size > 0 && d.Nodes[size-1].Type != NodeBlank {
+        d.insert(size, &Node{Type: NodeBlank, Newline: d.newline()})
+    }
+    d.insert(len(d.Nodes), n)
+    return nil
+}
+
+// lookup 查找给定路径的键值对节点，不存在时返回-1
+func (d *Document) lookup(keys []string) int {
+    for _, sec := range d.sections() {
+        if !hasPrefix(keys, sec.path) {
+            continue
+        }
+        for i := sec.header + 1; i < sec.end; i++ {
+            n := d.Nodes[i]
+            if n.Type == NodeKeyValue && equalPath(keys[len(sec.path):], n.Key) {
+                return i
+            }
+        }
+    }
+    return -1
+}
+
+// sections 获取文档中所有的表
+func (d *Document) sections() []*section {
+    secs := []*section{{header: -1, end: len(d.Nodes)}}
+    arrays := make(map[string]int)
+    for i, n := range d.Nodes {
+        if n.Type != NodeTable && n.Type != NodeArrayTable {
+            continue
+        }
+        secs[len(secs)-1].end = i
+        // 上级路径中的表数组指向其最后一个元素
+        path := make([]string, 0, len(n.Key)+1)
+        for j, k := range n.Key {
+            path = append(path, k)
+            if j == len(n.Key)-1 {
+                break
+            }
+            if cnt, ok := arrays[strings.Join(path, "\x00")]; ok {
+                path = append(path, strconv.Itoa(cnt-1))
+            }
+        }
+        if n.Type == NodeArrayTable {
+            name := strings.Join(path, "\x00")
+            path = append(path, strconv.Itoa(arrays[name]))
+            arrays[name]++
+        }
+        secs = append(secs, &section{path: path, header: i, end: len(d.Nodes)})
+    }
+    return secs
+}
+
+// insert 在给定位置插入节点
+func (d *Document) insert(pos int, n *Node) {
+    // 文档最后一行没有换行符时需要补充
+    if pos > 0 && d.Nodes[pos-1].Newline == "" {
+        d.Nodes[pos-1].Newline = d.newline()
+        n.Newline = ""
+    }
+    d.Nodes = append(d.Nodes, nil)
+    copy(d.Nodes[pos+1:], d.Nodes[pos:])
+    d.Nodes[pos] = n
+}
+
+// newline 获取文档使用的换行符
+func (d *Document) newline() string {
+    for _, n := range d.Nodes {
+        if n.Newline != "" {
+            return n.Newline
+        }
+    }
+    return "\n"
+}
+
+func formatKey(keys []string) string {
+    names := make([]string, len(keys))
+    for i, k := range keys {
+        names[i] = formatter.FmtTomlKey(k)
+    }
+    return strings.Join(names, ".")
+}
+
+func hasPrefix(keys, prefix []string) bool {
+    return len(keys) >= len(prefix) && equalPath(keys[:len(prefix)], prefix)
+}
+
+func equalPath(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,286 @@
+/**
+ * semantic diff of two parsed toml documents.
+ * values are compared by meaning instead of spelling, so 1_000 equals 1000 and
+ * moving keys or tables around in a document is not reported as a change.
+ */
+package diff
+
+import (
+    "math"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// ChangeType 变更类型
+type ChangeType string
+
+// define change types
+const (
+    Added    ChangeType = "added"
+    Removed  ChangeType = "removed"
+    Modified ChangeType = "modified"
+)
+
+// ArrayPolicy 数组的比较方式
+type ArrayPolicy int
+
+// define array policies
+const (
+    Ordered   ArrayPolicy = iota // 按下标比较，元素顺序变化视为修改
+    Unordered                    // 忽略元素顺序，只报告新增以及删除的元素
+)
+
+// Options 比较选项
+type Options struct {
+    Arrays ArrayPolicy            // 数组的默认比较方式
+    Paths  map[string]ArrayPolicy // 指定路径上数组的比较方式，路径格式与Change.Path相同，数组下标可以使用*
+}
+
+// Change 一处变更
+type Change struct {
+    Type ChangeType
+    Path string        // 变更的路径，如 servers.alpha.ip、ports[1]
+    Keys []string      // 路径的各级键名，数组下标为数字
+    Old  *xtype.Object // 原来的值，新增时为nil
+    New  *xtype.Object // 新的值，删除时为nil
+}
+
+// Compare 比较两个对象，按遍历顺序返回所有变更，两者相同时返回空列表
+// 表按键名比较，新文档中键的顺序不同不视为变更；opts为nil时数组按下标比较
+func Compare(old *xtype.Object, new *xtype.Object, opts *Options) []Change {
+    if opts == nil {
+        opts = &Options{}
+    }
+    d := &differ{opts: opts, changes: make([]Change, 0)}
+    d.compare(nil, old, new)
+    return d.changes
+}
+
+// Equal 判断两个值的含义是否相同
+func Equal(a *xtype.Object, b *xtype.Object) bool {
+    return len(Compare(a, b, &Options{Arrays: Ordered})) == 0
+}
+
+type differ struct {
+    opts    *Options
+    changes []Change
+}
+
+func (d *differ) add(t ChangeType, keys []string, old *xtype.Object, new *xtype.Object) {
+    keys = append([]string(nil), keys...)
+    d.changes = append(d.changes, Change{Type: t, Path: FormatPath(keys), Keys: keys, Old: old, New: new})
+}
+
+func (d *differ) compare(keys []string, old *xtype.Object, new *xtype.Object) {
+    switch {
+    case old == nil && new == nil:
+        return
+    case old == nil:
+        d.add(Added, keys, nil, new)
+        return
+    case new == nil:
+        d.add(Removed, keys, old, nil)
+        return
+    }
+    oldMap, oldIsMap := old.Value.(*xtype.Map)
+    newMap, newIsMap := new.Value.(*xtype.Map)
+    if oldIsMap && newIsMap && kindOf(oldMap) == kindOf(newMap) {
+        if oldMap.IsArray() && newMap.IsArray() {
+            d.compareArray(keys, oldMap, newMap)
+        } else {
+            d.compareTable(keys, oldMap, newMap)
+        }
+        return
+    }
+    if oldIsMap || newIsMap || !scalarEqual(old, new) {
+        d.add(Modified, keys, old, new)
+    }
+}
+
+// compareTable 按键名比较，先报告原有键的删除及修改，再按新文档中的顺序报告新增的键
+func (d *differ) compareTable(keys []string, old *xtype.Map, new *xtype.Map) {
+    for _, k := range old.Keys {
+        path := append(keys, k.Value)
+        if nk := new.GetKey(k.Value); nk != nil {
+            d.compare(path, old.Data[k], new.Data[nk])
+        } else {
+            d.add(Removed, path, old.Data[k], nil)
+        }
+    }
+    for _, k := range new.Keys {
+        if old.GetKey(k.Value) == nil {
+            d.add(Added, append(keys, k.Value), nil, new.Data[k])
+        }
+    }
+}
+
+// compareArray 比较数组，删除的元素按下标从大到小报告，依次应用时下标始终有效
+func (d *differ) compareArray(keys []string, old *xtype.Map, new *xtype.Map) {
+    oldItems := items(old)
+    newItems := items(new)
+    if d.policy(keys) == Unordered {
+        d.compareUnordered(keys, oldItems, newItems)
+        return
+    }
+    common := len(oldItems)
+    if len(newItems) < common {
+        common = len(newItems)
+    }
+    for i := 0; i < common; i++ {
+        d.compare(append(keys, strconv.Itoa(i)), oldItems[i], newItems[i])
+    }
+    for i := len(oldItems) - 1; i >= common; i-- {
+        d.add(Removed, append(keys, strconv.Itoa(i)), oldItems[i], nil)
+    }
+    for i := common; i < len(newItems); i++ {
+        d.add(Added, append(keys, strconv.Itoa(i)), nil, newItems[i])
+    }
+}
+
+// compareUnordered 忽略顺序比较数组，相同的元素按出现次数匹配
+// 删除的元素使用原数组中的下标，新增的元素使用新数组中的下标
+func (d *differ) compareUnordered(keys []string, oldItems []*xtype.Object, newItems []*xtype.Object) {
+    matched := make([]bool, len(newItems))
+    removed := make([]int, 0)
+    for i, item := range oldItems {
+        found := false
+        for j := range newItems {
+            if !matched[j] && Equal(item, newItems[j]) {
+                matched[j] = true
+                found = true
+                break
+            }
+        }
+        if !found {
+            removed = append(removed, i)
+        }
+    }
+    for i := len(removed) - 1; i >= 0; i-- {
+        d.add(Removed, append(keys, strconv.Itoa(removed[i])), oldItems[removed[i]], nil)
+    }
+    for j, item := range newItems {
+        if !matched[j] {
+            d.add(Added, append(keys, strconv.Itoa(j)), nil, item)
+        }
+    }
+}
+
+// policy 获取路径上数组的比较方式
+func (d *differ) policy(keys []string) ArrayPolicy {
+    for pattern, policy := range d.opts.Paths {
+        if MatchPath(pattern, keys) {
+            return policy
+        }
+    }
+    return d.opts.Arrays
+}
+
+// matchPath 判断路径是否与模式匹配，模式中的*匹配任意一级
+func MatchPath(pattern string, keys []string) bool {
+    parts, err := xtype.ParsePath(pattern)
+    if err != nil || len(parts) != len(keys) {
+        return false
+    }
+    for i, p := range parts {
+        if p != xtype.Wildcard && p != keys[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// kindOf 区分表与数组，空的Map既可以是空表也可以是空数组
+func kindOf(m *xtype.Map) int {
+    if len(m.Keys) == 0 {
+        return 0
+    }
+    if m.IsArray() {
+        return 1
+    }
+    return 2
+}
+
+// items 按下标顺序返回数组元素
+func items(m *xtype.Map) []*xtype.Object {
+    list := make([]*xtype.Object, 0, len(m.Keys))
+    for _, k := range m.Keys {
+        list = append(list, m.Data[k])
+    }
+    return list
+}
+
+// scalarEqual 比较两个标量的含义
+func scalarEqual(a *xtype.Object, b *xtype.Object) bool {
+    if a.Type != b.Type {
+        return false
+    }
+    av := util.String(a.Value)
+    bv := util.String(b.Value)
+    switch a.Type {
+    case xtype.TypeNumber:
+        return numberEqual(av, bv)
+    case xtype.TypeDatetime:
+        return strings.Replace(av, " ", "T", 1) == strings.Replace(bv, " ", "T", 1)
+    }
+    return av == bv
+}
+
+// numberEqual 比较两个数字，整数与浮点数不相等
+func numberEqual(a string, b string) bool {
+    a = util.NormalizeNumber(a)
+    b = util.NormalizeNumber(b)
+    if a == b {
+        return true
+    }
+    if isFloat(a) != isFloat(b) {
+        return false
+    }
+    fa, errA := strconv.ParseFloat(a, 64)
+    fb, errB := strconv.ParseFloat(b, 64)
+    if errA != nil || errB != nil {
+        return false
+    }
+    if math.IsNaN(fa) && math.IsNaN(fb) {
+        return true
+    }
+    return fa == fb
+}
+
+func isFloat(v string) bool {
+    return strings.ContainsAny(v, ".eE") || strings.HasSuffix(v, "inf") || strings.HasSuffix(v, "nan")
+}
+
+// FormatPath 将键名拼接为路径，数组下标写作[n]，包含特殊字符的键使用双引号，结果可以由xtype.ParsePath解析
+func FormatPath(keys []string) string {
+    buf := strings.Builder{}
+    for i, k := range keys {
+        if i > 0 && util.IsNonNegativeInt(k) {
+            buf.WriteString("[" + k + "]")
+            continue
+        }
+        if i > 0 {
+            buf.WriteString(".")
+        }
+        if isBareKey(k) {
+            buf.WriteString(k)
+        } else {
+            buf.WriteString("\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(k) + "\"")
+        }
+    }
+    return buf.String()
+}
+
+func isBareKey(k string) bool {
+    if k == "" || k == xtype.Wildcard {
+        return false
+    }
+    for _, c := range k {
+        if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+            return false
+        }
+    }
+    return true
+}
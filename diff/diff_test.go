@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/whencome/toml2x/parser"
+	"github.com/whencome/toml2x/xtype"
+)
+
+func mustParse(t *testing.T, toml string) *xtype.Object {
+	obj, err := parser.ParseTable(toml)
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	return obj
+}
+
+func TestCompare(t *testing.T) {
+	old := mustParse(t, `
+title = "demo"
+size = 1_000
+ports = [80, 443]
+[server]
+host = "localhost"
+"a.b" = 1
+[[users]]
+name = "tom"
+`)
+	new := mustParse(t, `
+size = 1000
+ports = [80, 8443, 9000]
+[[users]]
+name = "jerry"
+[server]
+host = "example.com"
+"a.b" = 1
+debug = true
+`)
+	expected := `- title = "demo"
+~ ports[1]: 443 -> 8443
++ ports[2] = 9000
+~ server.host: "localhost" -> "example.com"
++ server.debug = true
+~ users[0].name: "tom" -> "jerry"
+`
+	changes := Compare(old, new, nil)
+	if rs := Text(changes); rs != expected {
+		t.Logf("expected:\n%s\ngot:\n%s\n", expected, rs)
+		t.Fail()
+	}
+	if changes := Compare(old, old, nil); len(changes) != 0 {
+		t.Logf("expected no changes, got %v\n", changes)
+		t.Fail()
+	}
+}
+
+func TestCompareUnordered(t *testing.T) {
+	old := mustParse(t, "tags = ['a', 'b', 'c', 'b']\nhosts = [1, 2]\n")
+	new := mustParse(t, "tags = ['b', 'c', 'd', 'a']\nhosts = [2, 1]\n")
+
+	expected := "- tags[3] = \"b\"\n+ tags[2] = \"d\"\n"
+	if rs := Text(Compare(old, new, &Options{Arrays: Unordered})); rs != expected {
+		t.Logf("unordered: expected %q, got %q\n", expected, rs)
+		t.Fail()
+	}
+
+	opts := &Options{Arrays: Unordered, Paths: map[string]ArrayPolicy{"hosts": Ordered}}
+	rs := Text(Compare(old, new, opts))
+	if !strings.Contains(rs, "~ hosts[0]: 1 -> 2\n~ hosts[1]: 2 -> 1\n") {
+		t.Logf("ordered hosts: unexpected %q\n", rs)
+		t.Fail()
+	}
+}
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		a     string
+		b     string
+		equal bool
+	}{
+		{"0x10", "16", true},
+		{"1e3", "1000.0", true},
+		{"1", "1.0", false},
+		{"nan", "+nan", true},
+		{"1979-05-27 07:32:00Z", "1979-05-27T07:32:00Z", true},
+		{"'1'", "1", false},
+		{"[]", "{}", true},
+		{"{ a = 1, b = 2 }", "{ b = 2, a = 1 }", true},
+	}
+	for _, c := range cases {
+		a, err := parser.ParseSingle(c.a)
+		if err != nil {
+			t.Fatalf("parse %s failed: %s\n", c.a, err)
+		}
+		b, err := parser.ParseSingle(c.b)
+		if err != nil {
+			t.Fatalf("parse %s failed: %s\n", c.b, err)
+		}
+		if Equal(a, b) != c.equal {
+			t.Logf("Equal(%s, %s): expected %v\n", c.a, c.b, c.equal)
+			t.Fail()
+		}
+	}
+}
+
+func TestReports(t *testing.T) {
+	old := mustParse(t, "a = 1\n\"x/y\" = 'v'\nlist = [1, 2, 3]\n")
+	new := mustParse(t, "a = 2\nlist = [1]\nb = { c = true }\n")
+	changes := Compare(old, new, nil)
+
+	patch, err := Patch(changes)
+	if err != nil {
+		t.Fatalf("patch failed: %s\n", err)
+	}
+	expected := `[
+  {
+    "op": "replace",
+    "path": "/a",
+    "value": 2
+  },
+  {
+    "op": "remove",
+    "path": "/x~1y"
+  },
+  {
+    "op": "remove",
+    "path": "/list/2"
+  },
+  {
+    "op": "remove",
+    "path": "/list/1"
+  },
+  {
+    "op": "add",
+    "path": "/b",
+    "value": {
+      "c": true
+    }
+  }
+]
+`
+	if patch != expected {
+		t.Logf("expected patch:\n%s\ngot:\n%s\n", expected, patch)
+		t.Fail()
+	}
+
+	rs, err := Json(changes[:2])
+	if err != nil {
+		t.Fatalf("json failed: %s\n", err)
+	}
+	if !strings.Contains(rs, "\"type\": \"modified\",\n    \"path\": \"a\",\n    \"old\": 1,\n    \"new\": 2") ||
+		!strings.Contains(rs, "\"path\": \"\\\"x/y\\\"\",\n    \"old\": \"v\"\n") {
+		t.Logf("unexpected json: %s\n", rs)
+		t.Fail()
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	keys := []string{"servers", "a.b", "0", "say \"hi\""}
+	path := FormatPath(keys)
+	if path != `servers."a.b"[0]."say \"hi\""` {
+		t.Logf("unexpected path %s\n", path)
+		t.Fail()
+	}
+	parsed, err := xtype.ParsePath(path)
+	if err != nil || strings.Join(parsed, "|") != strings.Join(keys, "|") {
+		t.Logf("path should round trip, got %v %v\n", parsed, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,101 @@
+package diff
+
+import (
+    "bytes"
+    "encoding/json"
+    "strings"
+
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Text 输出便于阅读的变更列表，每行一处变更，值使用toml格式
+//   + path = value     新增
+//   - path = value     删除
+//   ~ path: old -> new 修改
+func Text(changes []Change) string {
+    buf := bytes.Buffer{}
+    for _, c := range changes {
+        switch c.Type {
+        case Added:
+            buf.WriteString("+ " + c.Path + " = " + c.New.TomlValue())
+        case Removed:
+            buf.WriteString("- " + c.Path + " = " + c.Old.TomlValue())
+        case Modified:
+            buf.WriteString("~ " + c.Path + ": " + c.Old.TomlValue() + " -> " + c.New.TomlValue())
+        }
+        buf.WriteString("\n")
+    }
+    return buf.String()
+}
+
+// jsonChange 变更的json格式
+type jsonChange struct {
+    Type ChangeType      `json:"type"`
+    Path string          `json:"path"`
+    Old  json.RawMessage `json:"old,omitempty"`
+    New  json.RawMessage `json:"new,omitempty"`
+}
+
+// Json 输出变更列表的json数组
+func Json(changes []Change) (string, error) {
+    list := make([]jsonChange, 0, len(changes))
+    for _, c := range changes {
+        list = append(list, jsonChange{Type: c.Type, Path: c.Path, Old: rawJson(c.Old), New: rawJson(c.New)})
+    }
+    return encodeJson(list)
+}
+
+// patchOperation RFC 6902 中的操作
+type patchOperation struct {
+    Op    string          `json:"op"`
+    Path  string          `json:"path"`
+    Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch 输出RFC 6902格式的JSON Patch，按顺序应用到原文档的json上即可得到新文档
+func Patch(changes []Change) (string, error) {
+    list := make([]patchOperation, 0, len(changes))
+    for _, c := range changes {
+        op := patchOperation{Path: Pointer(c.Keys)}
+        switch c.Type {
+        case Added:
+            op.Op = "add"
+            op.Value = rawJson(c.New)
+        case Removed:
+            op.Op = "remove"
+        case Modified:
+            op.Op = "replace"
+            op.Value = rawJson(c.New)
+        }
+        list = append(list, op)
+    }
+    return encodeJson(list)
+}
+
+// Pointer 将键名转换为RFC 6901 JSON Pointer
+func Pointer(keys []string) string {
+    escaper := strings.NewReplacer("~", "~0", "/", "~1")
+    buf := strings.Builder{}
+    for _, k := range keys {
+        buf.WriteString("/" + escaper.Replace(k))
+    }
+    return buf.String()
+}
+
+func rawJson(obj *xtype.Object) json.RawMessage {
+    if obj == nil {
+        return nil
+    }
+    return json.RawMessage(obj.Json(false))
+}
+
+func encodeJson(v interface{}) (string, error) {
+    buf := bytes.Buffer{}
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(v); err != nil {
+        return "", err
+    }
+    return buf.String(), nil
+}
@@ -0,0 +1,95 @@
+package toml2x
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/cst"
+    "github.com/whencome/toml2x/diff"
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Get 获取配置中路径对应的值，路径的格式见xtype.ParsePath，如 database.port、servers[0].ip
+func Get(toml string, path string, opts *Options) (*xtype.Object, error) {
+    obj, err := parseWithOptions("table", toml, opts)
+    if err != nil {
+        return nil, err
+    }
+    return obj.Get(path)
+}
+
+// Set 修改配置中路径对应的值，路径不存在时新增，返回修改后的配置内容
+// 优先只替换值的原文，注释、空行以及其他内容保持不变，表数组中的元素使用下标定位，如 servers[0].ip，
+// 值位于行内表或者行内数组中时替换整个行内表或者数组；无法原样保留时重新生成整个文档，此时注释会丢失。
+// 重新生成的文档中变量已展开、包含的文件已合并、加密的值已解密，因此设置了这些选项时返回错误
+func Set(toml string, path string, value *xtype.Object, opts *Options) (string, error) {
+    obj, err := parseWithOptions("table", toml, opts)
+    if err != nil {
+        return "", err
+    }
+    if err := obj.Value.(*xtype.Map).Set(path, value); err != nil {
+        return "", err
+    }
+    keys, err := xtype.ParsePath(path)
+    if err != nil {
+        return "", err
+    }
+    if edited, ok := setLossless(toml, obj, keys, value); ok {
+        // 修改后的文档必须与修改后的数据一致，新增的键在文档中的位置可能不同
+        if rs, err := parseWithOptions("table", edited, opts); err == nil && diff.Equal(rs, obj) {
+            return edited, nil
+        }
+    }
+    if opts != nil && (opts.Interpolation != nil || opts.Include != nil || opts.Keys != nil) {
+        return "", errors.New(path + ": the document has to be rewritten, which is not supported with interpolation, include or decryption")
+    }
+    return obj.Toml(false), nil
+}
+
+// setLossless 使用具体语法树修改值，只改动值所在的行
+// obj 修改后的数据，用于判断路径是否经过数组以及生成行内表或数组的新值
+func setLossless(toml string, obj *xtype.Object, keys []string, value *xtype.Object) (string, bool) {
+    doc, err := cst.Parse(toml)
+    if err != nil {
+        return "", false
+    }
+    names := make([]string, len(keys))
+    for i, k := range keys {
+        names[i] = formatter.FmtTomlKey(k)
+    }
+    for i, k := range keys {
+        m, ok := obj.Value.(*xtype.Map)
+        if !ok {
+            return "", false
+        }
+        // 行内表以及数组作为整体替换
+        if prefix := strings.Join(names[:i], "."); i > 0 {
+            if _, ok := doc.Get(prefix); ok {
+                if err := doc.Set(prefix, obj.TomlValue()); err != nil {
+                    return "", false
+                }
+                return doc.String(), true
+            }
+        }
+        // 数组中的元素只有以[[表头]]定义时才能通过下标定位，新增的元素在文档末尾添加表头
+        if m.IsArray() && !doc.HasTable(strings.Join(names[:i+1], ".")) {
+            if i == 0 || k != strconv.Itoa(len(m.Keys)-1) || !doc.HasTable(strings.Join(names[:i], ".")+".0") {
+                return "", false
+            }
+            if err := doc.InsertTable(strings.Join(names[:i], "."), true); err != nil {
+                return "", false
+            }
+        }
+        key := m.GetKey(k)
+        if key == nil {
+            break
+        }
+        obj = m.Data[key]
+    }
+    if err := doc.Set(strings.Join(names, "."), value.TomlValue()); err != nil {
+        return "", false
+    }
+    return doc.String(), true
+}
@@ -3,6 +3,7 @@ package formatter
 import (
     "bytes"
     "encoding/json"
+    "fmt"
     "github.com/whencome/toml2x/util"
     "strings"
 )
@@ -49,39 +50,15 @@ func FmtString(str string) string {
     return buffer.String()
 }
 
-// FmtPhpString 格式化为PHP字符串形式
+// FmtPhpString 格式化为PHP单引号字符串，对反斜线以及单引号进行转义
 func FmtPhpString(str string) string {
-    str = strings.TrimSpace(str)
-    if str == "" {
-        return "''"
-    }
-    chars := []rune(str)
-    charsSize := len(chars)
     buffer := bytes.Buffer{}
     buffer.WriteRune('\'')
-    for i := 0; i < charsSize; i++ {
-        if chars[i] == '\'' && ((i == 0) || (i > 0 && chars[i-1] != '\\')) {
+    for _, c := range str {
+        if c == '\\' || c == '\'' {
             buffer.WriteRune('\\')
-            buffer.WriteRune('\'')
-            continue
-        }
-        // 去除转义
-        if chars[i] == '\\' {
-            if i+1 < charsSize {
-                // 引号
-                if chars[i+1] == '\'' {
-                    buffer.WriteRune('\\')
-                    buffer.WriteRune('\'')
-                    i += 1
-                    continue
-                }
-            }
         }
-        if (i == 0 && chars[i] == '\\' && (i+1 == charsSize || chars[i+1] == '\n')) ||
-            (i > 0 && chars[i] == '\\' && chars[i-1] != '\\' && (i+1 == charsSize || chars[i+1] == '\n')) {
-            continue
-        }
-        buffer.WriteRune(chars[i])
+        buffer.WriteRune(c)
     }
     buffer.WriteRune('\'')
     return buffer.String()
@@ -95,7 +72,7 @@ func FmtPhpKey(k string) string {
 }
 
 func FmtJsonKey(k string) string {
-    return FmtString(k)
+    return FmtJsonString(k)
 }
 
 func FmtJsonString(v interface{}) string {
@@ -105,3 +82,122 @@ func FmtJsonString(v interface{}) string {
     }
     return string(fmtRs)
 }
+
+// FmtTomlString 格式化为TOML字符串，包含换行的内容使用多行字符串形式
+func FmtTomlString(str string) string {
+    return tomlString(str, strings.ContainsRune(str, '\n'))
+}
+
+func tomlString(str string, multiLine bool) string {
+    buffer := bytes.Buffer{}
+    if multiLine {
+        buffer.WriteString("\"\"\"\n")
+    } else {
+        buffer.WriteRune('"')
+    }
+    for _, c := range str {
+        switch {
+        case c == '"' || c == '\\':
+            buffer.WriteRune('\\')
+            buffer.WriteRune(c)
+        case c == '\n' && multiLine:
+            buffer.WriteRune(c)
+        case c == '\t':
+            buffer.WriteString("\\t")
+        case c == '\n':
+            buffer.WriteString("\\n")
+        case c == '\r':
+            buffer.WriteString("\\r")
+        case c < 0x20 || c == 0x7f:
+            buffer.WriteString(fmt.Sprintf("\\u%04X", c))
+        default:
+            buffer.WriteRune(c)
+        }
+    }
+    if multiLine {
+        buffer.WriteString("\"\"\"")
+    } else {
+        buffer.WriteRune('"')
+    }
+    return buffer.String()
+}
+
+// FmtTomlKey 格式化TOML键名，仅包含字母、数字、下划线以及中划线的键使用裸键，否则使用引号
+func FmtTomlKey(k string) string {
+    if k == "" {
+        return "\"\""
+    }
+    for _, c := range k {
+        if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-') {
+            return tomlString(k, false)
+        }
+    }
+    return k
+}
+
+// yamlSpecial 不加引号时会被YAML（包括1.1版本）解析为布尔值或者null的字符串，比较时不区分大小写
+var yamlSpecial = map[string]bool{
+    "y": true, "n": true, "yes": true, "no": true, "on": true, "off": true,
+    "true": true, "false": true, "null": true, "~": true, "<<": true, "=": true,
+}
+
+// FmtYamlString 格式化为YAML字符串，可以作为普通标量时不加引号，否则使用双引号
+// 布尔值、null、类似数字或者日期的字符串，以及包含YAML特殊字符的字符串都会加引号
+func FmtYamlString(str string) string {
+    if isYamlPlain(str) {
+        return str
+    }
+    buffer := bytes.Buffer{}
+    buffer.WriteRune('"')
+    for _, c := range str {
+        switch {
+        case c == '"' || c == '\\':
+            buffer.WriteRune('\\')
+            buffer.WriteRune(c)
+        case c == '\t':
+            buffer.WriteString("\\t")
+        case c == '\n':
+            buffer.WriteString("\\n")
+        case c == '\r':
+            buffer.WriteString("\\r")
+        case c < 0x20 || c == 0x7f || c == 0x85 || c == 0x2028 || c == 0x2029 || c == 0xfeff:
+            buffer.WriteString(fmt.Sprintf("\\u%04X", c))
+        default:
+            buffer.WriteRune(c)
+        }
+    }
+    buffer.WriteRune('"')
+    return buffer.String()
+}
+
+// FmtYamlKey 格式化YAML键名，规则与FmtYamlString相同
+func FmtYamlKey(k string) string {
+    return FmtYamlString(k)
+}
+
+// isYamlPlain 判断字符串是否可以作为YAML普通标量原样输出
+func isYamlPlain(str string) bool {
+    if str == "" || yamlSpecial[strings.ToLower(str)] {
+        return false
+    }
+    // 以数字、正负号或者小数点开头的内容可能被解析为数字、日期或者.inf、.nan
+    if strings.IndexByte("0123456789+-.", str[0]) >= 0 {
+        return false
+    }
+    // 以指示符开头
+    if strings.IndexByte("?:,[]{}#&*!|>'\"%@`", str[0]) >= 0 {
+        return false
+    }
+    if str[0] == ' ' || str[len(str)-1] == ' ' || str[len(str)-1] == ':' {
+        return false
+    }
+    if strings.Contains(str, ": ") || strings.Contains(str, " #") {
+        return false
+    }
+    for _, c := range str {
+        if c < 0x20 || c == 0x7f || c == 0x85 || c == 0x2028 || c == 0x2029 || c == 0xfeff {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,76 @@
+package formatter
+
+import (
+    "fmt"
+)
+
+// Limits 解析不可信输入时的资源限制，值为0表示不限制
+type Limits struct {
+    MaxDocumentSize int // 文档的最大字节数
+    MaxDepth        int // 数组以及行内表的最大嵌套层数
+    MaxKeys         int // 键的最大数量，不包括数组元素
+    MaxStringLength int // 字符串的最大字节数
+    MaxArrayTables  int // [[table]]定义的表数组元素的最大总数
+}
+
+// define limit names
+const (
+    LimitDocumentSize = "MaxDocumentSize"
+    LimitDepth        = "MaxDepth"
+    LimitKeys         = "MaxKeys"
+    LimitStringLength = "MaxStringLength"
+    LimitArrayTables  = "MaxArrayTables"
+)
+
+// LimitError 输入超出资源限制
+type LimitError struct {
+    Limit  string // 超出的限制，如 MaxDepth
+    Max    int    // 限制的值
+    File   string
+    Line   int // 超出限制的位置，为0时表示整个文档
+    Column int
+}
+
+// Message 不包含位置的错误信息
+func (e *LimitError) Message() string {
+    return fmt.Sprintf("%s exceeded: limit is %d", e.Limit, e.Max)
+}
+
+func (e *LimitError) Error() string {
+    msg := e.Message()
+    if e.Line <= 0 {
+        if e.File != "" {
+            return e.File + ": " + msg
+        }
+        return msg
+    }
+    if e.File == "" {
+        return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, msg)
+    }
+    return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, msg)
+}
+
+// Check 检查值是否超出指定的限制，超出时返回*LimitError
+// limit 限制的名称；n 当前的值；line、column 当前的位置
+func (l *Limits) Check(limit string, n int, line int, column int) error {
+    if l == nil {
+        return nil
+    }
+    max := 0
+    switch limit {
+    case LimitDocumentSize:
+        max = l.MaxDocumentSize
+    case LimitDepth:
+        max = l.MaxDepth
+    case LimitKeys:
+        max = l.MaxKeys
+    case LimitStringLength:
+        max = l.MaxStringLength
+    case LimitArrayTables:
+        max = l.MaxArrayTables
+    }
+    if max <= 0 || n <= max {
+        return nil
+    }
+    return &LimitError{Limit: limit, Max: max, Line: line, Column: column}
+}
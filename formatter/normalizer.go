@@ -4,10 +4,20 @@ import (
     "errors"
     "github.com/whencome/toml2x/util"
     "strings"
+    "unicode/utf8"
 )
 
 // Normalize 对输入的配置进行标准化处理，以便于后续解析
 func Normalize(snippet string) (string, error) {
+    return NormalizeWithLimits(snippet, nil)
+}
+
+// NormalizeWithLimits 对输入的配置进行标准化处理，同时检查资源限制，超出限制时返回*LimitError
+// 标准化时不解析键名，键的数量按键值对的数量计算
+func NormalizeWithLimits(snippet string, limits *Limits) (string, error) {
+    if err := limits.Check(LimitDocumentSize, len(snippet), 0, 0); err != nil {
+        return "", err
+    }
     // 处理换行符
     snippet = strings.ReplaceAll(snippet, "\r\n", "\n")
     snippet = strings.ReplaceAll(snippet, "\n\r", "\n")
@@ -15,22 +25,77 @@ func Normalize(snippet string) (string, error) {
     snippet = strings.ReplaceAll(snippet, "\t", "")
 
     // Run, char by char.
-    normalized := ""
+    // 使用Builder拼接结果，避免逐字符拼接字符串带来的平方级开销
+    normalized := strings.Builder{}
+    normalized.Grow(len(snippet))
     openString := false
     openLString := false
     openMString := false
     openMLString := false
     openBrackets := 0
     openKeygroup := false
-    lineBuffer := ""
+    // 当前行的起始位置以及当前行是否只包含空白，错误信息中使用当前行的内容
+    lineStart := 0
+    lineBlank := true
+    // 数组内被合并的换行数量，在数组所在行结束后补齐，以保持行号不变
+    mergedLines := 0
+    // 资源限制相关的计数
+    line := 1
+    openBraces := 0
+    keys := 0
+    arrayTables := 0
+    strLen := 0
 
     chars := []rune(snippet)
     charsSize := len(chars)
+    lineBuffer := func(i int) string {
+        if i > charsSize {
+            i = charsSize
+        }
+        return string(chars[lineStart:i])
+    }
+    check := func(limit string, n int, i int) error {
+        return limits.Check(limit, n, line, i-lineStart+1)
+    }
     for i := 0; i < charsSize; i++ {
         keep := true
-        if chars[i] == '[' && !openString && !openLString && !openMString && !openMLString {
+        inString := openString || openLString || openMString || openMLString
+        if limits != nil {
+            var err error
+            switch {
+            case inString:
+                strLen += utf8.RuneLen(chars[i])
+                err = check(LimitStringLength, strLen, i)
+            case chars[i] == '"' || chars[i] == '\'':
+                strLen = 0
+            case chars[i] == '=':
+                keys++
+                err = check(LimitKeys, keys, i)
+            case chars[i] == '{':
+                openBraces++
+                err = check(LimitDepth, openBraces+openBrackets+1, i)
+            case chars[i] == '}' && openBraces > 0:
+                openBraces--
+            case chars[i] == '[' && openBrackets == 0 && lineBlank:
+                if i+1 < charsSize && chars[i+1] == '[' {
+                    arrayTables++
+                    err = check(LimitArrayTables, arrayTables, i)
+                }
+            case chars[i] == '[' && openKeygroup:
+                // [[table]] 的第二个括号
+            case chars[i] == '[':
+                err = check(LimitDepth, openBraces+openBrackets+1, i)
+            }
+            if err != nil {
+                return "", err
+            }
+        }
+        if chars[i] == '\n' {
+            line++
+        }
+        if chars[i] == '[' && !inString {
             openBrackets++
-            if openBrackets == 1 && strings.TrimSpace(lineBuffer) == "" {
+            if openBrackets == 1 && lineBlank {
                 openKeygroup = true
             }
         } else if chars[i] == ']' && !openString && !openLString && !openMString && !openMLString {
@@ -40,20 +105,19 @@ func Normalize(snippet string) (string, error) {
                     openKeygroup = false
                 }
             } else {
-                return "", errors.New("Unexpected ']' on : " + lineBuffer)
+                return "", errors.New("Unexpected ']' on : " + lineBuffer(i))
             }
         } else if openBrackets > 0 && chars[i] == '\n' {
             if openKeygroup {
-                return "", errors.New("Multi-line keygroup definition is not allowed on: " + lineBuffer)
+                return "", errors.New("Multi-line keygroup definition is not allowed on: " + lineBuffer(i))
             }
             keep = false
         } else if (openString || openLString) && chars[i] == '\n' {
-            return "", errors.New("Multi-line string not allowed on: " + lineBuffer)
+            return "", errors.New("Multi-line string not allowed on: " + lineBuffer(i))
         } else if ((i > 0 && chars[i] == '"' && chars[i-1] != '\\') || (i == 0 && chars[i] == '"')) && !openLString && !openMLString {
             if charsSize >= i+3 && string(chars[i:i+3]) == `"""` {
                 i += 2
-                normalized += `"""`
-                lineBuffer += `"""`
+                normalized.WriteString(`"""`)
                 keep = false
                 openMString = !openMString
             } else if !openMString {
@@ -62,20 +126,19 @@ func Normalize(snippet string) (string, error) {
         } else if chars[i] == '\'' && !openString && !openMString {
             if charsSize >= i+3 && string(chars[i:i+3]) == "'''" {
                 i += 2
-                normalized += "'''"
-                lineBuffer += "'''"
+                normalized.WriteString("'''")
                 keep = false
                 openMLString = !openMLString
             } else if !openMLString {
                 openLString = !openLString
             }
-        } else if chars[i] == '\\' && chars[i-1] != '\\' && !util.RuneInArray(chars[i+1], []rune{'b', 't', 'n', 'f', 'r', 'u', 'U', '"', '\\', ' '}) {
+        } else if chars[i] == '\\' && i > 0 && i+1 < charsSize && chars[i-1] != '\\' && !util.RuneInArray(chars[i+1], []rune{'b', 't', 'n', 'f', 'r', 'u', 'U', '"', '\\', ' '}) {
             if openString {
                 return "", errors.New("Reserved special characters inside strings are not allowed: " + string(chars[i]) + string(chars[i+1]))
             }
             if openMString {
                 for {
-                    if chars[i] == '\n' || chars[i+1] == ' ' {
+                    if i+1 < charsSize && (chars[i] == '\n' || chars[i+1] == ' ') {
                         i++
                         keep = false
                     } else {
@@ -95,12 +158,20 @@ func Normalize(snippet string) (string, error) {
 
         // raw lines
         if i < charsSize {
-            lineBuffer += string(chars[i])
             if chars[i] == '\n' {
-                lineBuffer = ""
+                lineStart = i + 1
+                lineBlank = true
+            } else if chars[i] != ' ' {
+                lineBlank = false
             }
             if keep {
-                normalized += string(chars[i])
+                normalized.WriteRune(chars[i])
+                if chars[i] == '\n' && mergedLines > 0 {
+                    normalized.WriteString(strings.Repeat("\n", mergedLines))
+                    mergedLines = 0
+                }
+            } else if chars[i] == '\n' {
+                mergedLines++
             }
         }
     }
@@ -125,5 +196,5 @@ func Normalize(snippet string) (string, error) {
         return "", errors.New("syntax error found on TOML document. Missing closing key group delimiter")
     }
 
-    return normalized, nil
+    return normalized.String(), nil
 }
@@ -0,0 +1,88 @@
+package formatter
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// scaledExample 将example.toml重复拼接到指定大小
+func scaledExample(b *testing.B, size int) string {
+	tomlBytes, err := ioutil.ReadFile("../example.toml")
+	if err != nil {
+		b.Fatalf("read file content failed: %s\n", err)
+	}
+	buf := strings.Builder{}
+	for buf.Len() < size {
+		buf.Write(tomlBytes)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func TestNormalizeKeepsLines(t *testing.T) {
+	toml := "a = [\n  1, # one\n  2\n]\nb = 1\n"
+	normalized, err := Normalize(toml)
+	if err != nil {
+		t.Logf("normalize toml failed: %s\n", err)
+		t.Fail()
+		return
+	}
+	lines := strings.Split(normalized, "\n")
+	if len(lines) != 6 || strings.TrimSpace(lines[4]) != "b = 1" {
+		t.Logf("line numbers should be kept: %q\n", normalized)
+		t.Fail()
+	}
+}
+
+func benchmarkNormalize(b *testing.B, size int) {
+	toml := scaledExample(b, size)
+	b.SetBytes(int64(len(toml)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Normalize(toml); err != nil {
+			b.Fatalf("normalize toml failed: %s\n", err)
+		}
+	}
+}
+
+func BenchmarkNormalize1MB(b *testing.B) {
+	benchmarkNormalize(b, 1<<20)
+}
+
+func BenchmarkNormalize10MB(b *testing.B) {
+	benchmarkNormalize(b, 10<<20)
+}
+
+func TestNormalizeLimits(t *testing.T) {
+	limits := &Limits{
+		MaxDocumentSize: 200,
+		MaxDepth:        3,
+		MaxKeys:         5,
+		MaxStringLength: 10,
+		MaxArrayTables:  2,
+	}
+	cases := []struct {
+		toml  string
+		limit string
+	}{
+		{strings.Repeat("# padding\n", 30), LimitDocumentSize},
+		{"a = [[[[1]]]]", LimitDepth},
+		{"a = { b = [{ c = [1] }] }", LimitDepth},
+		{"a = 1\nb = 2\n[c]\nd = 3\ne = 4\nf = { g = 5 }", LimitKeys},
+		{"a = \"0123456789ab\"", LimitStringLength},
+		{"[[a]]\n[[a]]\n[[b]]", LimitArrayTables},
+	}
+	for _, c := range cases {
+		_, err := NormalizeWithLimits(c.toml, limits)
+		if le, ok := err.(*LimitError); !ok || le.Limit != c.limit {
+			t.Logf("normalize %q: expected %s exceeded, got %v\n", c.toml, c.limit, err)
+			t.Fail()
+		}
+	}
+	if _, err := NormalizeWithLimits("[[a]]\nb = [[1, 2], [3]]\nc = 'short'", limits); err != nil {
+		t.Logf("normalize within limits failed: %s\n", err)
+		t.Fail()
+	}
+}
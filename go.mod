@@ -1,3 +1,3 @@
 module github.com/whencome/toml2x
 
-go 1.13
+go 1.16
@@ -0,0 +1,472 @@
+/**
+ * tokenizer of toml documents.
+ * the parser decides whether a key or a value is expected, so the lexer works in two modes:
+ * in key mode dots separate keys and [[ ]] delimit array of tables headers,
+ * in value mode dots, colons and signs are part of numbers and dates.
+ */
+package lexer
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/whencome/toml2x/xtype"
+)
+
+// define token types
+const (
+    EOF                    = iota
+    Error                         // 词法错误，Value为错误信息
+    Newline                       // 换行
+    Equals                        // =
+    Dot                           // .（仅键模式）
+    Comma                         // ,
+    LeftBracket                   // [
+    RightBracket                  // ]
+    DoubleLeftBracket             // [[（仅键模式）
+    DoubleRightBracket            // ]]（仅键模式）
+    LeftBrace                     // {
+    RightBrace                    // }
+    Bare                          // 裸键，或者数字、布尔值、日期等裸值
+    BasicString                   // "..."
+    LiteralString                 // '...'
+    MultiLineBasicString          // """..."""
+    MultiLineLiteralString        // '''...'''
+    Variable                      // ${NAME}形式的变量引用（仅值模式，且开启了Variables）
+)
+
+// define lexer modes
+const (
+    ModeKey   = iota // 读取键名
+    ModeValue        // 读取值
+)
+
+// Token 词法记号
+type Token struct {
+    Type   int
+    Value  string         // 字符串为转义处理后的内容，其他记号为原文
+    Raw    string         // 原文
+    Pos    xtype.Position // 记号在源文件中的位置
+    Offset int            // 记号在源内容中的字节偏移
+}
+
+// Lexer 词法分析器
+type Lexer struct {
+    src  string
+    file string
+    state
+
+    Variables bool // 值模式中是否识别未加引号的变量引用，如 port = ${PORT}
+
+    peeked   *Token // 预读的记号
+    peekMode int
+    peekEnd  state // 预读记号之后的位置
+}
+
+// state 读取位置
+type state struct {
+    offset int
+    line   int
+    col    int
+}
+
+// New 创建词法分析器，file为源文件名，用于记录位置
+func New(file string, src string) *Lexer {
+    return &Lexer{
+        src:   src,
+        file:  file,
+        state: state{line: 1, col: 1},
+    }
+}
+
+// TypeName 获取记号类型的名称
+func TypeName(t int) string {
+    switch t {
+    case EOF:
+        return "end of file"
+    case Error:
+        return "error"
+    case Newline:
+        return "newline"
+    case Equals:
+        return "'='"
+    case Dot:
+        return "'.'"
+    case Comma:
+        return "','"
+    case LeftBracket:
+        return "'['"
+    case RightBracket:
+        return "']'"
+    case DoubleLeftBracket:
+        return "'[['"
+    case DoubleRightBracket:
+        return "']]'"
+    case LeftBrace:
+        return "'{'"
+    case RightBrace:
+        return "'}'"
+    case Bare:
+        return "bare key or value"
+    case BasicString, MultiLineBasicString:
+        return "string"
+    case LiteralString, MultiLineLiteralString:
+        return "literal string"
+    case Variable:
+        return "variable"
+    }
+    return "unknown token"
+}
+
+// IsString 判断记号是否是字符串
+func (t Token) IsString() bool {
+    return t.Type == BasicString || t.Type == LiteralString || t.Type == MultiLineBasicString || t.Type == MultiLineLiteralString
+}
+
+// String 记号的描述，用于错误信息
+func (t Token) String() string {
+    switch t.Type {
+    case EOF, Newline, Error:
+        return TypeName(t.Type)
+    }
+    return strconv.Quote(t.Raw)
+}
+
+// Position 获取当前读取到的位置
+func (l *Lexer) Position() xtype.Position {
+    return xtype.Position{File: l.file, Line: l.line, Column: l.col}
+}
+
+// Peek 查看下一个记号，不移动读取位置
+func (l *Lexer) Peek(mode int) Token {
+    if l.peeked != nil && l.peekMode == mode {
+        return *l.peeked
+    }
+    saved := l.state
+    t := l.next(mode)
+    l.peeked = &t
+    l.peekMode = mode
+    l.peekEnd = l.state
+    l.state = saved
+    return t
+}
+
+// Next 读取下一个记号
+func (l *Lexer) Next(mode int) Token {
+    if l.peeked != nil && l.peekMode == mode {
+        t := *l.peeked
+        l.state = l.peekEnd
+        l.peeked = nil
+        return t
+    }
+    l.peeked = nil
+    return l.next(mode)
+}
+
+// SkipLine 跳过当前行剩余的内容（包括换行符），用于遇到错误后继续读取
+func (l *Lexer) SkipLine() {
+    l.peeked = nil
+    end := strings.IndexByte(l.src[l.offset:], '\n')
+    if end < 0 {
+        end = len(l.src) - l.offset - 1
+    }
+    l.advance(end + 1)
+}
+
+func (l *Lexer) next(mode int) Token {
+    l.skipSpaces()
+    start := l.offset
+    pos := l.Position()
+    if start >= len(l.src) {
+        return Token{Type: EOF, Pos: pos, Offset: start}
+    }
+
+    typ := -1
+    size := 1
+    switch c := l.src[start]; c {
+    case '\n':
+        typ = Newline
+    case '\r':
+        if !strings.HasPrefix(l.src[start:], "\r\n") {
+            return l.errorf(pos, "unexpected carriage return")
+        }
+        typ, size = Newline, 2
+    case '=':
+        typ = Equals
+    case ',':
+        typ = Comma
+    case '{':
+        typ = LeftBrace
+    case '}':
+        typ = RightBrace
+    case '[':
+        typ = LeftBracket
+        if mode == ModeKey && strings.HasPrefix(l.src[start:], "[[") {
+            typ, size = DoubleLeftBracket, 2
+        }
+    case ']':
+        typ = RightBracket
+        if mode == ModeKey && strings.HasPrefix(l.src[start:], "]]") {
+            typ, size = DoubleRightBracket, 2
+        }
+    case '.':
+        if mode == ModeKey {
+            typ = Dot
+        }
+    case '"', '\'':
+        return l.lexString(pos)
+    case '$':
+        if mode == ModeValue && l.Variables && strings.HasPrefix(l.src[start:], "${") {
+            return l.lexVariable(pos)
+        }
+    }
+    if typ >= 0 {
+        l.advance(size)
+        return l.token(typ, start, pos)
+    }
+    return l.lexBare(mode, pos)
+}
+
+// skipSpaces 跳过空白以及注释，不包括换行
+func (l *Lexer) skipSpaces() {
+    for l.offset < len(l.src) {
+        switch l.src[l.offset] {
+        case ' ', '\t':
+            l.advance(1)
+        case '#':
+            end := strings.IndexByte(l.src[l.offset:], '\n')
+            if end < 0 {
+                end = len(l.src) - l.offset
+            } else if end > 0 && l.src[l.offset+end-1] == '\r' {
+                end--
+            }
+            l.advance(end)
+        default:
+            return
+        }
+    }
+}
+
+// lexBare 读取裸键或者裸值
+func (l *Lexer) lexBare(mode int, pos xtype.Position) Token {
+    start := l.offset
+    l.advance(l.bareLength(mode, start))
+    if l.offset == start {
+        r, _ := utf8.DecodeRuneInString(l.src[start:])
+        return l.errorf(pos, "unexpected character %q", r)
+    }
+    // 日期与时间之间允许使用空格分隔，如 1979-05-27 07:32:00
+    if mode == ModeValue && l.offset-start == 10 && isDate(l.src[start:l.offset]) &&
+        l.offset+3 < len(l.src) && l.src[l.offset] == ' ' && isDigit(l.src[l.offset+1]) && isDigit(l.src[l.offset+2]) && l.src[l.offset+3] == ':' {
+        l.advance(1)
+        l.advance(l.bareLength(mode, l.offset))
+    }
+    return l.token(Bare, start, pos)
+}
+
+// bareLength 获取从start开始的裸键或裸值的长度
+func (l *Lexer) bareLength(mode int, start int) int {
+    i := start
+    for i < len(l.src) {
+        c := l.src[i]
+        if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c == '_' || c == '-' {
+            i++
+            continue
+        }
+        if mode == ModeValue && (c == '+' || c == '.' || c == ':') {
+            i++
+            continue
+        }
+        break
+    }
+    return i - start
+}
+
+// lexVariable 读取变量引用，变量引用不能跨行
+func (l *Lexer) lexVariable(pos xtype.Position) Token {
+    start := l.offset
+    end := strings.IndexAny(l.src[start:], "}\n")
+    if end < 0 || l.src[start+end] != '}' {
+        return l.errorf(pos, "unterminated variable reference")
+    }
+    l.advance(end + 1)
+    return l.token(Variable, start, pos)
+}
+
+// lexString 读取字符串
+func (l *Lexer) lexString(pos xtype.Position) Token {
+    start := l.offset
+    quote := l.src[start]
+    delim := l.src[start : start+1]
+    triple := `"""`
+    if quote == '\'' {
+        triple = "'''"
+    }
+    multiLine := strings.HasPrefix(l.src[start:], triple)
+    typ := BasicString
+    switch {
+    case quote == '"' && multiLine:
+        typ = MultiLineBasicString
+    case quote == '\'' && multiLine:
+        typ = MultiLineLiteralString
+    case quote == '\'':
+        typ = LiteralString
+    }
+
+    i := start + 1
+    if multiLine {
+        i = start + 3
+        // 紧跟在起始分隔符之后的换行会被忽略
+        if strings.HasPrefix(l.src[i:], "\n") {
+            i++
+        } else if strings.HasPrefix(l.src[i:], "\r\n") {
+            i += 2
+        }
+    }
+    buf := strings.Builder{}
+    for {
+        if i >= len(l.src) {
+            return l.errorf(pos, "missing closing string delimiter")
+        }
+        c := l.src[i]
+        if c == quote {
+            if !multiLine {
+                i++
+                break
+            }
+            // 多行字符串的结束分隔符之前允许最多两个引号
+            n := 0
+            for i+n < len(l.src) && l.src[i+n] == quote {
+                n++
+            }
+            if n >= 3 {
+                if n > 5 {
+                    return l.errorf(pos, "too many quotes at the end of multi-line string")
+                }
+                buf.WriteString(strings.Repeat(delim, n-3))
+                i += n
+                break
+            }
+            buf.WriteString(l.src[i : i+n])
+            i += n
+            continue
+        }
+        if c == '\n' && !multiLine {
+            return l.errorf(pos, "new lines not allowed on single line strings")
+        }
+        if c == '\\' && quote == '"' {
+            n, err := l.unescape(&buf, i, multiLine)
+            if err != nil {
+                return l.errorf(pos, "%s", err)
+            }
+            i += n
+            continue
+        }
+        if (c < 0x20 && c != '\t' && c != '\n' && c != '\r') || c == 0x7f {
+            return l.errorf(pos, "control character %q not allowed in strings", c)
+        }
+        buf.WriteByte(c)
+        i++
+    }
+    l.advance(i - start)
+    t := l.token(typ, start, pos)
+    t.Value = buf.String()
+    return t
+}
+
+// unescape 处理转义序列，返回转义序列的长度
+func (l *Lexer) unescape(buf *strings.Builder, i int, multiLine bool) (int, error) {
+    if i+1 >= len(l.src) {
+        return 0, fmt.Errorf("missing closing string delimiter")
+    }
+    switch c := l.src[i+1]; c {
+    case 'b':
+        buf.WriteByte('\b')
+    case 't':
+        buf.WriteByte('\t')
+    case 'n':
+        buf.WriteByte('\n')
+    case 'f':
+        buf.WriteByte('\f')
+    case 'r':
+        buf.WriteByte('\r')
+    case 'e':
+        buf.WriteByte(0x1b)
+    case '"':
+        buf.WriteByte('"')
+    case '\\':
+        buf.WriteByte('\\')
+    case 'u', 'U':
+        size := 4
+        if c == 'U' {
+            size = 8
+        }
+        if i+2+size > len(l.src) {
+            return 0, fmt.Errorf("invalid unicode escape")
+        }
+        code, err := strconv.ParseUint(l.src[i+2:i+2+size], 16, 32)
+        if err != nil || !utf8.ValidRune(rune(code)) {
+            return 0, fmt.Errorf("invalid unicode escape \\%c%s", c, l.src[i+2:i+2+size])
+        }
+        buf.WriteRune(rune(code))
+        return 2 + size, nil
+    case ' ', '\t', '\r', '\n':
+        // 多行字符串的行尾反斜线，忽略其后的空白以及换行
+        j := i + 1
+        for j < len(l.src) && (l.src[j] == ' ' || l.src[j] == '\t') {
+            j++
+        }
+        if !multiLine || j >= len(l.src) || (l.src[j] != '\n' && l.src[j] != '\r') {
+            return 0, fmt.Errorf("invalid escape sequence \\%c", c)
+        }
+        for j < len(l.src) && strings.IndexByte(" \t\r\n", l.src[j]) >= 0 {
+            j++
+        }
+        return j - i, nil
+    default:
+        return 0, fmt.Errorf("invalid escape sequence \\%c", c)
+    }
+    return 2, nil
+}
+
+// advance 向后移动n个字节，并更新行号与列号
+func (l *Lexer) advance(n int) {
+    for i := l.offset; i < l.offset+n; i++ {
+        if c := l.src[i]; c == '\n' {
+            l.line++
+            l.col = 1
+        } else if c&0xC0 != 0x80 {
+            l.col++
+        }
+    }
+    l.offset += n
+}
+
+func (l *Lexer) token(typ int, start int, pos xtype.Position) Token {
+    raw := l.src[start:l.offset]
+    return Token{Type: typ, Value: raw, Raw: raw, Pos: pos, Offset: start}
+}
+
+func (l *Lexer) errorf(pos xtype.Position, format string, args ...interface{}) Token {
+    return Token{Type: Error, Value: fmt.Sprintf(format, args...), Pos: pos, Offset: l.offset}
+}
+
+func isDigit(c byte) bool {
+    return c >= '0' && c <= '9'
+}
+
+// isDate 判断是否是 yyyy-mm-dd 格式的日期
+func isDate(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if i == 4 || i == 7 {
+            if s[i] != '-' {
+                return false
+            }
+        } else if !isDigit(s[i]) {
+            return false
+        }
+    }
+    return true
+}
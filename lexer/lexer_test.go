@@ -0,0 +1,96 @@
+package lexer
+
+import (
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	src := `[[fruit.variety]] # comment
+"quoted key".bare = 1979-05-27 07:32:00Z
+arr = [ +1.5e3, 'a\b' ]`
+	expected := []struct {
+		mode  int
+		typ   int
+		value string
+		pos   string
+	}{
+		{ModeKey, DoubleLeftBracket, "[[", "x.toml:1:1"},
+		{ModeKey, Bare, "fruit", "x.toml:1:3"},
+		{ModeKey, Dot, ".", "x.toml:1:8"},
+		{ModeKey, Bare, "variety", "x.toml:1:9"},
+		{ModeKey, DoubleRightBracket, "]]", "x.toml:1:16"},
+		{ModeKey, Newline, "\n", "x.toml:1:28"},
+		{ModeKey, BasicString, "quoted key", "x.toml:2:1"},
+		{ModeKey, Dot, ".", "x.toml:2:13"},
+		{ModeKey, Bare, "bare", "x.toml:2:14"},
+		{ModeKey, Equals, "=", "x.toml:2:19"},
+		{ModeValue, Bare, "1979-05-27 07:32:00Z", "x.toml:2:21"},
+		{ModeKey, Newline, "\n", "x.toml:2:41"},
+		{ModeKey, Bare, "arr", "x.toml:3:1"},
+		{ModeKey, Equals, "=", "x.toml:3:5"},
+		{ModeValue, LeftBracket, "[", "x.toml:3:7"},
+		{ModeValue, Bare, "+1.5e3", "x.toml:3:9"},
+		{ModeValue, Comma, ",", "x.toml:3:15"},
+		{ModeValue, LiteralString, `a\b`, "x.toml:3:17"},
+		{ModeValue, RightBracket, "]", "x.toml:3:23"},
+		{ModeValue, EOF, "", "x.toml:3:24"},
+	}
+	lex := New("x.toml", src)
+	for i, e := range expected {
+		if i%2 == 0 {
+			// 预读不应影响读取结果
+			lex.Peek(e.mode)
+		}
+		tok := lex.Next(e.mode)
+		if tok.Type != e.typ || tok.Value != e.value || tok.Pos.String() != e.pos {
+			t.Logf("token %d: expected %s %q at %s, got %s %q at %s\n", i, TypeName(e.typ), e.value, e.pos, TypeName(tok.Type), tok.Value, tok.Pos)
+			t.Fail()
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	cases := []struct {
+		src      string
+		expected string
+	}{
+		{`"tab\there \"quoted\" \u00E9\U0001F600"`, "tab\there \"quoted\" \u00e9\U0001F600"},
+		{`'C:\Users\nodejs'`, `C:\Users\nodejs`},
+		{"\"\"\"\nOne\nTwo\"\"\"", "One\nTwo"},
+		{"\"\"\"\\\n    The quick \\\n    fox.\"\"\"", "The quick fox."},
+		{"'''\nfirst\n   second\n'''", "first\n   second\n"},
+		{`"""ends with quotes"""""`, `ends with quotes""`},
+		{`''''quoted''''`, `'quoted'`},
+	}
+	for _, c := range cases {
+		tok := New("", c.src).Next(ModeValue)
+		if !tok.IsString() || tok.Value != c.expected || tok.Raw != c.src {
+			t.Logf("lex %s: expected %q, got %s %q\n", c.src, c.expected, TypeName(tok.Type), tok.Value)
+			t.Fail()
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		src string
+		pos string
+	}{
+		{`"unterminated`, "1:1"},
+		{"\"new\nline\"", "1:1"},
+		{`"bad \q escape"`, "1:1"},
+		{"key = @", "1:7"},
+	}
+	for _, c := range cases {
+		lex := New("", c.src)
+		var tok Token
+		for tok = lex.Next(ModeValue); tok.Type != Error && tok.Type != EOF; tok = lex.Next(ModeValue) {
+		}
+		if tok.Type != Error || tok.Pos.String() != c.pos {
+			t.Logf("lex %q: expected error at %s, got %s at %s\n", c.src, c.pos, TypeName(tok.Type), tok.Pos)
+			t.Fail()
+			continue
+		}
+		t.Logf("lex %q: %s\n", c.src, tok.Value)
+	}
+}
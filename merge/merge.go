@@ -0,0 +1,263 @@
+/**
+ * layered merge of toml documents, such as base, environment and local configuration.
+ * later layers override earlier ones; tables are merged key by key and arrays follow a
+ * configurable strategy. the layers are never modified, and every value of the result
+ * can be traced back to the layer and position it came from.
+ */
+package merge
+
+import (
+    "errors"
+    "strconv"
+
+    "github.com/whencome/toml2x/diff"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// ArrayStrategy 数组的合并方式
+type ArrayStrategy int
+
+// define array strategies
+const (
+    Replace    ArrayStrategy = iota // 使用后面的数组替换前面的数组
+    Append                          // 将后面数组的元素追加到前面的数组
+    MergeByKey                      // 元素为表时按Options.Key指定的字段匹配并合并，未匹配的元素追加到末尾
+)
+
+// DefaultKey 按键合并数组时默认用于匹配元素的字段
+const DefaultKey = "name"
+
+// Options 合并选项
+type Options struct {
+    Arrays       ArrayStrategy            // 数组的默认合并方式
+    Paths        map[string]ArrayStrategy // 指定路径上数组的合并方式，路径中的数组下标可以使用*
+    Key          string                   // 按键合并数组时用于匹配元素的字段，为空时使用DefaultKey
+    DeleteMarker string                   // 删除标记，值为该字符串的键会从合并结果中删除，为空时不启用
+}
+
+// Layer 一层配置
+type Layer struct {
+    Name string        // 层的名称，通常为文件名
+    Data *xtype.Object // 解析后的配置，必须是表
+}
+
+// Source 合并结果中一个值的来源
+type Source struct {
+    Path  string         // 值的路径，格式见diff.FormatPath
+    Layer string         // 值所在层的名称
+    Pos   xtype.Position // 值在该层中的位置
+}
+
+// Result 合并结果
+type Result struct {
+    Data    *xtype.Object
+    Sources []Source // 每个标量以及空表、空数组的来源，按结果中的顺序排列
+}
+
+// Source 获取路径对应的值的来源
+func (r *Result) Source(path string) (Source, bool) {
+    keys, err := xtype.ParsePath(path)
+    if err != nil {
+        return Source{}, false
+    }
+    path = diff.FormatPath(keys)
+    for _, s := range r.Sources {
+        if s.Path == path {
+            return s, true
+        }
+    }
+    return Source{}, false
+}
+
+// Merge 依次合并各层配置，后面的层覆盖前面的层
+// 表按键名合并，类型不同时使用后面的值；opts为nil时数组整体替换且不启用删除标记
+func Merge(layers []Layer, opts *Options) (*Result, error) {
+    if opts == nil {
+        opts = &Options{}
+    }
+    m := &merger{opts: opts, origins: make(map[*xtype.Object]string)}
+    if m.opts.Key == "" {
+        m.opts = &Options{Arrays: opts.Arrays, Paths: opts.Paths, Key: DefaultKey, DeleteMarker: opts.DeleteMarker}
+    }
+    var data *xtype.Object
+    for _, layer := range layers {
+        if layer.Data == nil || layer.Data.Type != xtype.TypeMap || layer.Data.Value.(*xtype.Map).IsArray() {
+            return nil, errors.New("layer " + layer.Name + " is not a table")
+        }
+        m.register(layer.Name, layer.Data)
+        data = m.merge(nil, data, layer.Data)
+    }
+    if data == nil {
+        data = xtype.NewMapObject(xtype.NewMap())
+    }
+    rs := &Result{Data: data, Sources: make([]Source, 0)}
+    m.trace(rs, nil, data)
+    return rs, nil
+}
+
+type merger struct {
+    opts    *Options
+    origins map[*xtype.Object]string // 值所在层的名称
+}
+
+// register 记录一层中所有值所在的层
+func (m *merger) register(name string, obj *xtype.Object) {
+    m.origins[obj] = name
+    if mp, ok := obj.Value.(*xtype.Map); ok {
+        for _, k := range mp.Keys {
+            m.register(name, mp.Data[k])
+        }
+    }
+}
+
+// merge 合并两个值，返回新的值，dst为nil时返回src去掉删除标记后的副本
+// 表以及数组总是重新创建，标量直接使用原对象
+func (m *merger) merge(keys []string, dst *xtype.Object, src *xtype.Object) *xtype.Object {
+    srcMap, ok := src.Value.(*xtype.Map)
+    if !ok {
+        return src
+    }
+    var dstMap *xtype.Map
+    if dst != nil {
+        dstMap, _ = dst.Value.(*xtype.Map)
+    }
+    var rs *xtype.Map
+    switch {
+    case isArray(srcMap, dstMap):
+        var items []*xtype.Object
+        if dstMap != nil && (len(dstMap.Keys) == 0 || dstMap.IsArray()) {
+            items = m.mergeArray(keys, elements(dstMap), elements(srcMap))
+        } else {
+            items = m.mergeArray(keys, nil, elements(srcMap))
+        }
+        rs = xtype.NewArrayMap()
+        for i, item := range items {
+            rs.Add(xtype.NewNumberKey(strconv.Itoa(i)), item)
+        }
+    case dstMap != nil && !dstMap.IsArray():
+        rs = m.mergeTable(keys, dstMap, srcMap)
+    default:
+        rs = m.mergeTable(keys, xtype.NewMap(), srcMap)
+    }
+    obj := &xtype.Object{Value: rs, Type: src.Type, Pos: src.Pos}
+    m.origins[obj] = m.origins[src]
+    return obj
+}
+
+// mergeTable 按键名合并两个表，dst中的键保持原来的顺序，新增的键追加到末尾
+func (m *merger) mergeTable(keys []string, dst *xtype.Map, src *xtype.Map) *xtype.Map {
+    rs := xtype.NewMap()
+    for _, k := range dst.Keys {
+        v := dst.Data[k]
+        if sk := src.GetKey(k.Value); sk != nil {
+            if m.isDeleted(src.Data[sk]) {
+                continue
+            }
+            v = m.merge(append(keys, k.Value), v, src.Data[sk])
+        }
+        rs.Add(copyKey(k), v)
+    }
+    for _, k := range src.Keys {
+        if dst.GetKey(k.Value) != nil || m.isDeleted(src.Data[k]) {
+            continue
+        }
+        rs.Add(copyKey(k), m.merge(append(keys, k.Value), nil, src.Data[k]))
+    }
+    return rs
+}
+
+// mergeArray 按路径上的合并方式合并两个数组的元素
+func (m *merger) mergeArray(keys []string, dst []*xtype.Object, src []*xtype.Object) []*xtype.Object {
+    items := make([]*xtype.Object, 0, len(dst)+len(src))
+    strategy := m.strategy(keys)
+    if strategy != Replace {
+        items = append(items, dst...)
+    }
+    for _, item := range src {
+        if strategy == MergeByKey {
+            if i := m.find(items, item); i >= 0 {
+                items[i] = m.merge(append(keys, strconv.Itoa(i)), items[i], item)
+                continue
+            }
+        }
+        items = append(items, m.merge(append(keys, strconv.Itoa(len(items))), nil, item))
+    }
+    return items
+}
+
+// find 查找与item的Key字段相同的元素，返回其下标
+func (m *merger) find(items []*xtype.Object, item *xtype.Object) int {
+    id := field(item, m.opts.Key)
+    if id == nil {
+        return -1
+    }
+    for i, v := range items {
+        if vid := field(v, m.opts.Key); vid != nil && diff.Equal(vid, id) {
+            return i
+        }
+    }
+    return -1
+}
+
+// strategy 获取路径上数组的合并方式
+func (m *merger) strategy(keys []string) ArrayStrategy {
+    for pattern, strategy := range m.opts.Paths {
+        if diff.MatchPath(pattern, keys) {
+            return strategy
+        }
+    }
+    return m.opts.Arrays
+}
+
+// isDeleted 判断值是否为删除标记
+func (m *merger) isDeleted(obj *xtype.Object) bool {
+    return m.opts.DeleteMarker != "" && obj.Type == xtype.TypeString && util.String(obj.Value) == m.opts.DeleteMarker
+}
+
+// trace 按顺序记录合并结果中每个值的来源
+func (m *merger) trace(rs *Result, keys []string, obj *xtype.Object) {
+    if mp, ok := obj.Value.(*xtype.Map); ok && len(mp.Keys) > 0 {
+        for _, k := range mp.Keys {
+            m.trace(rs, append(keys, k.Value), mp.Data[k])
+        }
+        return
+    }
+    if len(keys) == 0 {
+        return
+    }
+    rs.Sources = append(rs.Sources, Source{Path: diff.FormatPath(keys), Layer: m.origins[obj], Pos: obj.Pos})
+}
+
+// isArray 判断合并结果是否为数组，空的Map与另一方保持一致
+func isArray(src *xtype.Map, dst *xtype.Map) bool {
+    if len(src.Keys) > 0 {
+        return src.IsArray()
+    }
+    return dst != nil && dst.IsArray()
+}
+
+// elements 按顺序返回数组元素
+func elements(mp *xtype.Map) []*xtype.Object {
+    list := make([]*xtype.Object, 0, len(mp.Keys))
+    for _, k := range mp.Keys {
+        list = append(list, mp.Data[k])
+    }
+    return list
+}
+
+// field 获取表中的字段，不是表或者字段不存在时返回nil
+func field(obj *xtype.Object, name string) *xtype.Object {
+    mp, ok := obj.Value.(*xtype.Map)
+    if !ok || mp.IsArray() {
+        return nil
+    }
+    if k := mp.GetKey(name); k != nil {
+        return mp.Data[k]
+    }
+    return nil
+}
+
+func copyKey(k *xtype.Key) *xtype.Key {
+    return &xtype.Key{Value: k.Value, IsNumeric: k.IsNumeric, Pos: k.Pos}
+}
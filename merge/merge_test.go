@@ -0,0 +1,95 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/whencome/toml2x/parser"
+)
+
+func layer(t *testing.T, name string, toml string) Layer {
+	obj, err := parser.ParseWithOptions("table", toml, &parser.Options{File: name})
+	if err != nil {
+		t.Fatalf("parse %s failed: %s\n", name, err)
+	}
+	return Layer{Name: name, Data: obj}
+}
+
+func TestMerge(t *testing.T) {
+	base := layer(t, "base.toml", `
+name = "app"
+debug = false
+ports = [80, 443]
+[db]
+host = "localhost"
+port = 5432
+[[servers]]
+name = "a"
+ip = "10.0.0.1"
+[[servers]]
+name = "b"
+ip = "10.0.0.2"
+`)
+	prod := layer(t, "prod.toml", `
+debug = "__delete__"
+ports = [8443]
+[db]
+host = "db.example.com"
+pool = { size = 10, idle = "__delete__" }
+[[servers]]
+name = "b"
+ip = "192.168.0.2"
+[[servers]]
+name = "c"
+ip = "192.168.0.3"
+`)
+	cases := []struct {
+		opts     *Options
+		expected string
+	}{
+		{nil, `{"name":"app","debug":"__delete__","ports":[8443],"db":{"host":"db.example.com","port":5432,"pool":{"size":10,"idle":"__delete__"}},"servers":[{"name":"b","ip":"192.168.0.2"},{"name":"c","ip":"192.168.0.3"}]}`},
+		{&Options{Arrays: Append, DeleteMarker: "__delete__"}, `{"name":"app","ports":[80,443,8443],"db":{"host":"db.example.com","port":5432,"pool":{"size":10}},"servers":[{"name":"a","ip":"10.0.0.1"},{"name":"b","ip":"10.0.0.2"},{"name":"b","ip":"192.168.0.2"},{"name":"c","ip":"192.168.0.3"}]}`},
+		{&Options{Arrays: MergeByKey, Paths: map[string]ArrayStrategy{"ports": Replace}}, `{"name":"app","debug":"__delete__","ports":[8443],"db":{"host":"db.example.com","port":5432,"pool":{"size":10,"idle":"__delete__"}},"servers":[{"name":"a","ip":"10.0.0.1"},{"name":"b","ip":"192.168.0.2"},{"name":"c","ip":"192.168.0.3"}]}`},
+	}
+	for i, c := range cases {
+		rs, err := Merge([]Layer{base, prod}, c.opts)
+		if err != nil {
+			t.Fatalf("case %d: merge failed: %s\n", i, err)
+		}
+		if json := rs.Data.Json(true); json != c.expected {
+			t.Logf("case %d: expected\n%s\ngot\n%s\n", i, c.expected, json)
+			t.Fail()
+		}
+	}
+	// 输入的各层不会被修改
+	if json := base.Data.Json(true); json != `{"name":"app","debug":false,"ports":[80,443],"db":{"host":"localhost","port":5432},"servers":[{"name":"a","ip":"10.0.0.1"},{"name":"b","ip":"10.0.0.2"}]}` {
+		t.Logf("base layer was modified: %s\n", json)
+		t.Fail()
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	base := layer(t, "base.toml", "[db]\nhost = \"localhost\"\nport = 5432\ntags = []\n")
+	local := layer(t, "local.toml", "[db]\nport = 15432\n")
+	rs, err := Merge([]Layer{base, local}, nil)
+	if err != nil {
+		t.Fatalf("merge failed: %s\n", err)
+	}
+	expected := []string{"db.host base.toml:2:8", "db.port local.toml:2:8", "db.tags base.toml:4:8"}
+	if len(rs.Sources) != len(expected) {
+		t.Fatalf("expected %d sources, got %v\n", len(expected), rs.Sources)
+	}
+	for i, s := range rs.Sources {
+		if got := s.Path + " " + s.Pos.String(); got != expected[i] || s.Layer != s.Pos.File {
+			t.Logf("source %d: expected %s, got %s (%s)\n", i, expected[i], got, s.Layer)
+			t.Fail()
+		}
+	}
+	if s, ok := rs.Source("db.port"); !ok || s.Layer != "local.toml" {
+		t.Logf("db.port should come from local.toml, got %v %v\n", s, ok)
+		t.Fail()
+	}
+	if _, err := Merge([]Layer{{Name: "bad", Data: nil}}, nil); err == nil {
+		t.Logf("expected error for invalid layer\n")
+		t.Fail()
+	}
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+    "errors"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// DefaultIncludeKey 默认的包含指令键名
+const DefaultIncludeKey = "include"
+
+// Include 包含指令选项
+// 开启后文档顶层的 include = "common.toml" 或者 include = ["a.toml", "b.toml"] 会被替换为被包含文件的内容，
+// 路径相对于包含它的文件，可以使用../，但不能超出根目录，也不能使用绝对路径；
+// 被包含的文件按顺序合并，当前文件中的值覆盖被包含文件中的值，
+// 两侧都是表时递归合并，其他情况（包括数组）整体替换。被包含的文件也可以包含其他文件，但不能循环包含。
+// 资源限制对整个包含树生效：文档大小、键以及表数组元素的数量累计计算，包含的嵌套层数受MaxDepth限制
+type Include struct {
+    FS   fs.FS  // 读取被包含文件的文件系统，路径使用/分隔，Options.File是其中的路径；为nil时使用Root
+    Root string // FS为nil时被包含的文件所在的根目录，如项目的根目录；为空时使用Options.File所在的目录
+    Key  string // 包含指令的键名，为空时使用DefaultIncludeKey
+}
+
+// includer 处理包含指令
+type includer struct {
+    opts   *Options
+    fsys   fs.FS
+    key    string
+    prefix string // 为nil的FS使用的目录，用于错误信息中的文件名
+    // 整个包含树累计的资源计数
+    size        int
+    keys        int
+    arrayTables int
+}
+
+// resolveIncludes 处理文档中的包含指令，p为解析该文档的解析器，其资源计数在被包含的文件中继续累计
+func resolveIncludes(obj *xtype.Object, opts *Options, p *parser) error {
+    m := obj.Value.(*xtype.Map)
+    inc := &includer{opts: opts, fsys: opts.Include.FS, key: opts.Include.Key, size: p.size, keys: p.keys, arrayTables: p.arrayTables}
+    if inc.key == "" {
+        inc.key = DefaultIncludeKey
+    }
+    if m.GetKey(inc.key) == nil {
+        return nil
+    }
+    name := opts.File
+    if inc.fsys == nil {
+        inc.prefix = opts.Include.Root
+        if inc.prefix == "" {
+            inc.prefix = filepath.Dir(opts.File)
+        }
+        rel, err := relPath(inc.prefix, opts.File)
+        if err != nil {
+            return err
+        }
+        inc.fsys = os.DirFS(inc.prefix)
+        name = rel
+    }
+    name = path.Clean(filepath.ToSlash(name))
+    return inc.resolve(m, name, []string{name})
+}
+
+// relPath 获取文件相对于根目录的路径，文件不在根目录中时返回错误
+func relPath(root string, file string) (string, error) {
+    absRoot, err := filepath.Abs(root)
+    if err != nil {
+        return "", err
+    }
+    absFile, err := filepath.Abs(file)
+    if err != nil {
+        return "", err
+    }
+    rel, err := filepath.Rel(absRoot, absFile)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", errors.New(file + ": the file is outside the include root " + root)
+    }
+    return rel, nil
+}
+
+// resolve 将当前表中的包含指令替换为被包含文件的内容，stack为正在处理的文件，用于检测循环包含
+func (inc *includer) resolve(m *xtype.Map, name string, stack []string) error {
+    k := m.GetKey(inc.key)
+    if k == nil {
+        return nil
+    }
+    refs, err := inc.references(m.Data[k])
+    if err != nil {
+        return err
+    }
+    if err := m.Delete(strconv.Quote(inc.key)); err != nil {
+        return err
+    }
+
+    base := xtype.NewMap()
+    for _, ref := range refs {
+        raw := util.String(ref.Value)
+        if path.IsAbs(filepath.ToSlash(raw)) || filepath.IsAbs(raw) {
+            return &SyntaxError{Pos: ref.Pos, Msg: "invalid include path " + raw + ": absolute paths are not allowed"}
+        }
+        target := path.Join(path.Dir(name), filepath.ToSlash(raw))
+        if !fs.ValidPath(target) {
+            return &SyntaxError{Pos: ref.Pos, Msg: "invalid include path " + raw + ": outside the include root"}
+        }
+        if err := inc.opts.Limits.Check(formatter.LimitDepth, len(stack)+1, ref.Pos.Line, ref.Pos.Column); err != nil {
+            err.(*formatter.LimitError).File = ref.Pos.File
+            return err
+        }
+        for i, s := range stack {
+            if s == target {
+                return &SyntaxError{Pos: ref.Pos, Msg: "include cycle: " + strings.Join(append(stack[i:], target), " -> ")}
+            }
+        }
+        content, err := fs.ReadFile(inc.fsys, target)
+        if err != nil {
+            if pe, ok := err.(*fs.PathError); ok {
+                err = pe.Err
+            }
+            return &SyntaxError{Pos: ref.Pos, Msg: "include " + util.String(ref.Value) + ": " + err.Error()}
+        }
+        sub, err := inc.parse(target, string(content))
+        if err != nil {
+            return err
+        }
+        subMap := sub.Value.(*xtype.Map)
+        if err := inc.resolve(subMap, target, append(stack, target)); err != nil {
+            return err
+        }
+        base.Merge(subMap)
+    }
+    base.Merge(m)
+    *m = *base
+    return nil
+}
+
+// references 获取包含指令中的文件列表
+func (inc *includer) references(v *xtype.Object) ([]*xtype.Object, error) {
+    if v.Type == xtype.TypeString {
+        return []*xtype.Object{v}, nil
+    }
+    if m, ok := v.Value.(*xtype.Map); ok && (len(m.Keys) == 0 || m.IsArray()) {
+        refs := make([]*xtype.Object, 0, len(m.Keys))
+        for _, k := range m.Keys {
+            if m.Data[k].Type != xtype.TypeString {
+                return nil, &SyntaxError{Pos: m.Data[k].Pos, Msg: inc.key + " must be a string or an array of strings"}
+            }
+            refs = append(refs, m.Data[k])
+        }
+        return refs, nil
+    }
+    return nil, &SyntaxError{Pos: v.Pos, Msg: inc.key + " must be a string or an array of strings"}
+}
+
+// parse 使用相同的选项解析被包含的文件，文件名用于位置信息
+func (inc *includer) parse(name string, content string) (*xtype.Object, error) {
+    opts := *inc.opts
+    opts.File = name
+    if inc.prefix != "" {
+        opts.File = filepath.Join(inc.prefix, filepath.FromSlash(name))
+    }
+    p := newParser(content, &opts)
+    inc.size += len(content)
+    p.keys, p.arrayTables = inc.keys, inc.arrayTables
+    if err := p.checkLimit(formatter.LimitDocumentSize, inc.size, xtype.Position{File: opts.File}); err != nil {
+        return nil, err
+    }
+    obj, err := p.parseDocument()
+    inc.keys, inc.arrayTables = p.keys, p.arrayTables
+    return obj, err
+}
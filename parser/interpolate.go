@@ -0,0 +1,114 @@
+package parser
+
+import (
+    "errors"
+    "os"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/whencome/toml2x/lexer"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Interpolation 变量展开选项，开启后以下内容中的变量引用会在解析时展开：
+//   - 基本字符串（"..."以及"""..."""），字面量字符串（'...'）保持原样
+//   - 未加引号的单个变量引用，如 port = ${PORT:-5432}，结果默认为字符串
+// 引用的格式为 ${NAME} 或者 ${NAME:-default}，变量未设置或者为空时使用默认值，$$ 表示一个 $
+type Interpolation struct {
+    Lookup func(name string) (string, bool) // 查找变量的值，为nil时使用os.LookupEnv
+    Coerce bool                             // 未加引号的引用展开后按toml值解析，如 5432 解析为数字，无法解析时仍为字符串
+}
+
+// Expand 展开字符串中的变量引用，lookup为nil时使用os.LookupEnv
+func Expand(s string, lookup func(name string) (string, bool)) (string, error) {
+    rs, _, err := expand(s, lookup)
+    return rs, err
+}
+
+// expand 展开变量引用，出错时同时返回出错的引用在s中的偏移
+func expand(s string, lookup func(name string) (string, bool)) (string, int, error) {
+    if !strings.Contains(s, "$") {
+        return s, 0, nil
+    }
+    if lookup == nil {
+        lookup = os.LookupEnv
+    }
+    buf := strings.Builder{}
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if c != '$' || i+1 >= len(s) {
+            buf.WriteByte(c)
+            continue
+        }
+        switch s[i+1] {
+        case '$':
+            buf.WriteByte('$')
+            i++
+            continue
+        case '{':
+        default:
+            buf.WriteByte(c)
+            continue
+        }
+        end := strings.IndexByte(s[i:], '}')
+        if end < 0 {
+            return "", i, errors.New("unterminated variable reference")
+        }
+        ref := s[i+2 : i+end]
+        name, def, hasDefault := ref, "", false
+        if sep := strings.Index(ref, ":-"); sep >= 0 {
+            name, def, hasDefault = ref[:sep], ref[sep+2:], true
+        }
+        if !isVariableName(name) {
+            return "", i, errors.New("invalid variable name \"" + name + "\"")
+        }
+        val, ok := lookup(name)
+        switch {
+        case hasDefault && val == "":
+            val = def
+        case !ok:
+            return "", i, errors.New("variable " + name + " is not set")
+        }
+        buf.WriteString(val)
+        i += end
+    }
+    return buf.String(), 0, nil
+}
+
+// isVariableName 变量名由字母、数字以及下划线组成，不能以数字开头
+func isVariableName(name string) bool {
+    if name == "" || (name[0] >= '0' && name[0] <= '9') {
+        return false
+    }
+    for i := 0; i < len(name); i++ {
+        c := name[i]
+        if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+            return false
+        }
+    }
+    return true
+}
+
+// interpolate 展开字符串或者变量引用记号，返回展开后的值
+func (p *parser) interpolate(t lexer.Token) (*xtype.Object, error) {
+    opts := p.opts.Interpolation
+    val, offset, err := expand(t.Value, opts.Lookup)
+    if err != nil {
+        return nil, p.errorf(referencePos(t, offset), "%s", err)
+    }
+    if t.Type == lexer.Variable && opts.Coerce {
+        if obj := scalarObject(val); obj != nil {
+            return obj, nil
+        }
+    }
+    return xtype.NewStringObject(val), nil
+}
+
+// referencePos 获取记号中出错的引用的位置，单行字符串中没有转义时可以精确定位，否则返回记号的位置
+func referencePos(t lexer.Token, offset int) xtype.Position {
+    pos := t.Pos
+    if t.Type == lexer.BasicString && t.Raw[1:len(t.Raw)-1] == t.Value {
+        pos.Column += 1 + utf8.RuneCountInString(t.Value[:offset])
+    }
+    return pos
+}
@@ -1,355 +1,636 @@
 package parser
 
 import (
-    "bytes"
-    "errors"
+    "fmt"
     "strconv"
     "strings"
 
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/lexer"
     "github.com/whencome/toml2x/util"
     "github.com/whencome/toml2x/xtype"
 )
 
+// maxNestingDepth 未设置MaxDepth时数组以及行内表的最大嵌套层数，避免恶意输入导致无限递归
+const maxNestingDepth = 1000
+
+// Options 解析选项
+type Options struct {
+    File   string            // 配置文件名，用于记录键和值的位置
+    Limits *formatter.Limits // 资源限制，超出限制时返回*formatter.LimitError
+    // 变量展开选项，为nil时不展开，变量未设置时返回*SyntaxError
+    Interpolation *Interpolation
+    // 包含指令选项，为nil时include是普通的键，只对整个文档有效
+    Include *Include
+    // 判断键路径是否是秘密，是秘密时错误信息中不引用值的内容；路径由键名组成，不包含数组下标
+    Secret func(keys []string) bool
+    // 解密字符串值，keys为值的键路径，与Secret相同；value不是加密的值时ok为false，
+    // 否则返回toml格式的值的原文，解析后代替原来的字符串；为nil时加密的值保持原样，见crypt.Decrypter
+    Decrypt func(keys []string, value string) (literal string, ok bool, err error)
+}
+
+// SyntaxError 语法错误，记录了错误在源文件中的位置
+type SyntaxError struct {
+    Pos xtype.Position
+    Msg string
+}
+
+func (e *SyntaxError) Error() string {
+    if !e.Pos.IsValid() {
+        return e.Msg
+    }
+    return e.Pos.String() + ": " + e.Msg
+}
+
 // Parse 解析toml内容
 func Parse(contentType string, toml string) (*xtype.Object, error) {
+    return ParseWithOptions(contentType, toml, nil)
+}
+
+// ParseWithOptions 使用指定的选项解析toml内容
+func ParseWithOptions(contentType string, toml string, opts *Options) (*xtype.Object, error) {
+    if opts == nil {
+        opts = &Options{}
+    }
+    p := newParser(toml, opts)
+    if err := p.checkLimit(formatter.LimitDocumentSize, len(toml), xtype.Position{File: opts.File}); err != nil {
+        return nil, err
+    }
     if contentType == "single" {
-        return ParseSingle(toml)
+        return p.parseSingle()
+    }
+    obj, err := p.parseDocument()
+    if err != nil {
+        return nil, err
     }
-    return ParseTable(toml)
+    if opts.Include != nil {
+        if err := resolveIncludes(obj, opts, p); err != nil {
+            return nil, err
+        }
+    }
+    return obj, nil
+}
+
+// Validate 校验toml文档，返回发现的所有错误，每个错误为*SyntaxError或者*formatter.LimitError
+// 遇到语法错误时跳过所在行继续解析，超出资源限制时停止解析
+func Validate(toml string, opts *Options) []error {
+    if opts == nil {
+        opts = &Options{}
+    }
+    p := newParser(toml, opts)
+    if err := p.checkLimit(formatter.LimitDocumentSize, len(toml), xtype.Position{File: opts.File}); err != nil {
+        return []error{err}
+    }
+    p.recover = true
+    obj, _ := p.parseDocument()
+    if len(p.errors) == 0 && opts.Include != nil {
+        if err := resolveIncludes(obj, opts, p); err != nil {
+            return []error{err}
+        }
+    }
+    return p.errors
 }
 
 // ParseTable 解析复杂数据
 func ParseTable(toml string) (*xtype.Object, error) {
-    arr := &xtype.Map{}
+    return newParser(toml, &Options{}).parseDocument()
+}
 
-    // split lines
-    arrToml := strings.Split(toml, "\n")
-    arrSize := len(arrToml)
+// ParseSingle 解析单个值
+func ParseSingle(val string) (*xtype.Object, error) {
+    return newParser(val, &Options{}).parseSingle()
+}
 
-    var recurseKeys []string
-    for ln := 0; ln < arrSize; ln++ {
-        line := []rune(strings.TrimSpace(arrToml[ln]))
-        lineSize := len(line)
+// parser 递归下降解析器，直接构造xtype对象
+type parser struct {
+    lex  *lexer.Lexer
+    opts *Options
+    root *xtype.Map
+    // 当前表头所指向的表
+    current *xtype.Map
+    // 通过[[table]]定义的表数组
+    arrays map[*xtype.Map]bool
+    // 行内表以及数组，定义之后不能再通过表头或者点号分隔的键扩展
+    static map[*xtype.Map]bool
+    // 是否在遇到语法错误后继续解析，以及已经发现的错误
+    recover bool
+    errors  []error
+    // 出错的记号是否是换行，此时不需要再跳过所在行
+    lineEnded bool
+    // 当前表头的键名，以及正在解析的值的键路径，用于判断值是否是秘密
+    header []string
+    path   []string
+    // 资源限制相关的计数
+    size        int
+    depth       int
+    keys        int
+    arrayTables int
+}
 
-        // Skip commented and empty lines
-        if lineSize == 0 || line[0] == '#' {
+func newParser(toml string, opts *Options) *parser {
+    root := xtype.NewMap()
+    lex := lexer.New(opts.File, toml)
+    lex.Variables = opts.Interpolation != nil
+    return &parser{
+        lex:     lex,
+        opts:    opts,
+        root:    root,
+        current: root,
+        arrays:  make(map[*xtype.Map]bool),
+        static:  make(map[*xtype.Map]bool),
+        size:    len(toml),
+    }
+}
+
+// parseDocument 解析整个文档
+// document = { newline | table | array-table | keyval newline }
+func (p *parser) parseDocument() (*xtype.Object, error) {
+    for {
+        var err error
+        p.lineEnded = false
+        switch t := p.lex.Peek(lexer.ModeKey); t.Type {
+        case lexer.EOF:
+            return xtype.NewMapObject(p.root), nil
+        case lexer.Newline:
+            p.lex.Next(lexer.ModeKey)
             continue
+        case lexer.LeftBracket, lexer.DoubleLeftBracket:
+            p.path = nil
+            err = p.parseTableHeader()
+        default:
+            p.path = p.header
+            err = p.parseKeyValue(p.current)
         }
-
-        // Array of Tables
-        if string(line[0:2]) == "[[" && string(line[lineSize-2:]) == "]]" {
-            tableName := line[2 : lineSize-2]
-            aTables := parseTomlTableName(tableName)
-            if len(aTables) <= 0 {
-                continue
+        if err == nil {
+            err = p.expectLineEnd()
+        }
+        if err != nil {
+            if !p.recover {
+                return nil, err
             }
-            recurseKeys = arr.GetRecursiveIndexedKeys(aTables)
-        } else if string(line[0:1]) == "[" && string(line[lineSize-1:]) == "]" {
-            tableName := line[1 : lineSize-1]
-            aTables := parseTomlTableName(tableName)
-            if len(aTables) <= 0 {
-                continue
+            p.errors = append(p.errors, err)
+            if _, ok := err.(*formatter.LimitError); ok {
+                return nil, err
             }
-            recurseKeys = make([]string, len(aTables))
-            copy(recurseKeys, aTables)
-        } else if util.RunesContains(line, '=') {
-            rawLine := string(line)
-            pos := strings.Index(rawLine, "=")
-            field := strings.TrimSpace(rawLine[0:pos])
-            val := strings.TrimSpace(rawLine[pos+1:])
-            valSize := len(val)
-            if valSize >= 3 && val[0:3] == `"""` {
-                if valSize == 3 || (valSize > 3 && val[valSize-3:] != `"""`) {
-                    for {
-                        ln++
-                        nextLine := strings.TrimSpace(arrToml[ln])
-                        val += "\n"
-                        val += arrToml[ln]
-                        if nextLine == `"""` || (len(nextLine) > 3 && nextLine[len(nextLine)-3:] == `"""`) {
-                            break
-                        }
-                    }
-                }
+            p.skipLine()
+        }
+    }
+}
+
+// skipLine 跳过出错的行剩余的内容
+func (p *parser) skipLine() {
+    for !p.lineEnded {
+        switch t := p.lex.Next(lexer.ModeKey); t.Type {
+        case lexer.Newline, lexer.EOF:
+            return
+        case lexer.Error:
+            p.lex.SkipLine()
+            return
+        }
+    }
+}
+
+// parseSingle 解析单个值，值的前后只允许出现空白、注释以及换行
+func (p *parser) parseSingle() (*xtype.Object, error) {
+    p.skipNewlines(lexer.ModeValue)
+    obj, err := p.parseValue()
+    if err != nil {
+        return nil, err
+    }
+    p.skipNewlines(lexer.ModeValue)
+    if t := p.lex.Next(lexer.ModeValue); t.Type != lexer.EOF {
+        return nil, p.unexpected(t, "end of value")
+    }
+    return obj, nil
+}
+
+// parseTableHeader 解析表头 [a.b] 或者表数组 [[a.b]]
+func (p *parser) parseTableHeader() error {
+    open := p.lex.Next(lexer.ModeKey)
+    array := open.Type == lexer.DoubleLeftBracket
+    keys, err := p.parseKey()
+    if err != nil {
+        return err
+    }
+    closeType := lexer.RightBracket
+    if array {
+        closeType = lexer.DoubleRightBracket
+    }
+    if t := p.lex.Next(lexer.ModeKey); t.Type != closeType {
+        return p.unexpected(t, lexer.TypeName(closeType))
+    }
+    p.header = keyNames(nil, keys)
+
+    dst := p.root
+    for i, kt := range keys {
+        last := i == len(keys)-1
+        k := dst.GetKey(kt.Value)
+        if k == nil {
+            // 表头中定义的键以表头的位置为准
+            m := xtype.NewMap()
+            if last && array {
+                m = xtype.NewArrayMap()
             }
-            if valSize >= 3 && val[0:3] == `'''` {
-                if valSize == 3 || (valSize > 3 && val[valSize-3:] != `'''`) {
-                    for {
-                        ln++
-                        nextLine := strings.TrimSpace(arrToml[ln])
-                        val += "\n"
-                        val += arrToml[ln]
-                        if nextLine == `'''` || (len(nextLine) > 3 && nextLine[len(nextLine)-3:] == `'''`) {
-                            break
-                        }
-                    }
-                }
+            nk := newKey(kt)
+            nk.Pos = open.Pos
+            if err := p.addKey(dst, nk, newMapObject(m, open.Pos)); err != nil {
+                return err
             }
-            // 支持单引号，对单引号的内容进行一次转义
-            if valSize > 2 && val[0] == '\'' && val[1] != '\'' && val[valSize-1] == '\'' {
-                buf := bytes.Buffer{}
-                valChars := []rune(val)
-                valCharsSize := len(valChars)
-                for j := 0; j < valCharsSize; j++ {
-                    // 单引号里面的内容，不对双引号再进行转义
-                    if util.RuneInArray(valChars[j], []rune{'\\'}) {
-                        buf.WriteRune('\\')
-                        buf.WriteRune(valChars[j])
-                    } else {
-                        buf.WriteRune(valChars[j])
-                    }
-                }
-                val = buf.String()
+            if last && array {
+                p.arrays[m] = true
+                p.current, err = p.appendTable(m, open.Pos)
+                return err
             }
-            pathKeys := make([]string, len(recurseKeys))
-            copy(pathKeys, recurseKeys)
-            fieldKeys := parseTomlTableName([]rune(field))
-            pathKeys = append(pathKeys, fieldKeys...)
-            err := parseKeyValue(arr, pathKeys, val)
-            if err != nil {
-                return nil, err
+            dst = m
+            continue
+        }
+        v := dst.Data[k]
+        if v.Type != xtype.TypeMap {
+            return p.errorf(kt.Pos, "key %s is already defined as a %s", kt.Value, xtype.TypeName(v.Type))
+        }
+        m := v.Value.(*xtype.Map)
+        if err := p.checkStatic(m, kt); err != nil {
+            return err
+        }
+        if last && array {
+            if !p.arrays[m] {
+                return p.errorf(kt.Pos, "key %s is already defined and is not an array of tables", kt.Value)
+            }
+            p.current, err = p.appendTable(m, open.Pos)
+            return err
+        }
+        if p.arrays[m] {
+            if last {
+                return p.errorf(kt.Pos, "key %s is already defined as an array of tables", kt.Value)
             }
-        } else if string(line[0:1]) == "[" && string(line[lineSize-1:]) != "]" {
-            return nil, errors.New("Key groups have to be on a line by themselves: " + string(line))
-        } else {
-            return nil, errors.New("Syntax error on: " + string(line))
+            // 表数组指向其最后一个元素
+            m = lastTable(m)
         }
+        dst = m
+    }
+    p.current = dst
+    return nil
+}
+
+// appendTable 向表数组中追加一个新的表
+func (p *parser) appendTable(arr *xtype.Map, pos xtype.Position) (*xtype.Map, error) {
+    p.arrayTables++
+    if err := p.checkLimit(formatter.LimitArrayTables, p.arrayTables, pos); err != nil {
+        return nil, err
     }
+    m := xtype.NewMap()
+    k := xtype.NewNumberKey(strconv.Itoa(len(arr.Keys)))
+    k.Pos = pos
+    arr.Add(k, newMapObject(m, pos))
+    return m, nil
+}
 
-    return xtype.NewMapObject(arr), nil
+// addKey 向表中添加新的键，并检查键的数量限制
+func (p *parser) addKey(dst *xtype.Map, k *xtype.Key, obj *xtype.Object) error {
+    p.keys++
+    if err := p.checkLimit(formatter.LimitKeys, p.keys, k.Pos); err != nil {
+        return err
+    }
+    dst.Add(k, obj)
+    return nil
 }
 
-// ParseSingle 解析单个值
-func ParseSingle(val string) (*xtype.Object, error) {
-    val = strings.TrimSpace(val)
-    // 布尔值
-    if val == "true" || val == "false" {
-        return xtype.NewBoolObject(val), nil
+// parseKeyValue 解析键值对并保存到表中
+// keyval = key '=' value
+func (p *parser) parseKeyValue(table *xtype.Map) error {
+    keys, err := p.parseKey()
+    if err != nil {
+        return err
     }
-    // 字符串
-    if util.IsNumeric(val) {
-        return xtype.NewNumberObject(val), nil
+    if t := p.lex.Next(lexer.ModeKey); t.Type != lexer.Equals {
+        return p.unexpected(t, "'='")
     }
-    // 字符串解析，可能是复杂对象
-    chars := []rune(val)
-    charsSize := len(chars)
-    parsedVal := make([]rune, 0)
-    // 多行字符串
-    if string(chars[0:3]) == `'''` && string(chars[charsSize-3:charsSize]) == `'''` {
-        parsedVal = chars[3 : charsSize-3]
-        if parsedVal[0] == '\n' {
-            parsedVal = parsedVal[1:]
-        }
-        return xtype.NewStringObject(string(parsedVal)), nil
+    p.path = keyNames(p.path, keys)
+    obj, err := p.parseValue()
+    if err != nil {
+        return err
     }
-    if string(chars[0:3]) == `"""` && string(chars[charsSize-3:charsSize]) == `"""` {
-        parsedVal = chars[3 : charsSize-3]
-        if parsedVal[0] == '\n' {
-            parsedVal = parsedVal[1:]
+    return p.setValue(table, keys, obj)
+}
+
+// setValue 按键名列表保存值，点号分隔的键会隐式创建上级表，重复的键以后出现的值为准
+func (p *parser) setValue(table *xtype.Map, keys []lexer.Token, obj *xtype.Object) error {
+    dst := table
+    for i, kt := range keys {
+        k := dst.GetKey(kt.Value)
+        if i == len(keys)-1 {
+            if k == nil {
+                return p.addKey(dst, newKey(kt), obj)
+            }
+            dst.Data[k] = obj
+            return nil
         }
-        return xtype.NewStringObject(string(parsedVal)), nil
-    }
-    // 单行字符串
-    if chars[0] == '\'' && chars[charsSize-1] == '\'' {
-        if util.RunesContains(chars, '\n') {
-            return nil, errors.New("new lines not allowed on single line string literals")
+        if k == nil {
+            m := xtype.NewMap()
+            if err := p.addKey(dst, newKey(kt), newMapObject(m, kt.Pos)); err != nil {
+                return err
+            }
+            dst = m
+            continue
         }
-        return xtype.NewStringObject(string(chars[1 : charsSize-1])), nil
+        v := dst.Data[k]
+        if v.Type != xtype.TypeMap {
+            return p.errorf(kt.Pos, "key %s is already defined as a %s", kt.Value, xtype.TypeName(v.Type))
+        }
+        dst = v.Value.(*xtype.Map)
+        if err := p.checkStatic(dst, kt); err != nil {
+            return err
+        }
+        if p.arrays[dst] {
+            dst = lastTable(dst)
+        }
+    }
+    return nil
+}
+
+// checkStatic 检查表是否是行内表或者数组
+func (p *parser) checkStatic(m *xtype.Map, kt lexer.Token) error {
+    if !p.static[m] {
+        return nil
     }
-    if chars[0] == '"' && chars[charsSize-1] == '"' {
-        return xtype.NewStringObject(string(chars[1 : charsSize-1])), nil
+    if m.IsArray() {
+        return p.errorf(kt.Pos, "key %s is already defined as an array", kt.Value)
     }
-    // Single line array (normalized)
-    if chars[0] == '[' && chars[charsSize-1] == ']' {
-        arr, err := parseArray(chars)
+    return p.errorf(kt.Pos, "key %s is already defined as an inline table", kt.Value)
+}
+
+// parseKey 解析键名
+// key = simple-key { '.' simple-key }
+func (p *parser) parseKey() ([]lexer.Token, error) {
+    keys := make([]lexer.Token, 0, 1)
+    for {
+        t := p.lex.Next(lexer.ModeKey)
+        if t.Type != lexer.Bare && t.Type != lexer.BasicString && t.Type != lexer.LiteralString {
+            return nil, p.unexpected(t, "key")
+        }
+        if t.Type != lexer.Bare {
+            if err := p.checkLimit(formatter.LimitStringLength, len(t.Value), t.Pos); err != nil {
+                return nil, err
+            }
+        }
+        keys = append(keys, t)
+        if p.lex.Peek(lexer.ModeKey).Type != lexer.Dot {
+            return keys, nil
+        }
+        p.lex.Next(lexer.ModeKey)
+    }
+}
+
+// parseValue 解析值
+// value = string | number | boolean | datetime | array | inline-table
+func (p *parser) parseValue() (*xtype.Object, error) {
+    t := p.lex.Next(lexer.ModeValue)
+    var obj *xtype.Object
+    switch {
+    case t.IsString():
+        if err := p.checkLimit(formatter.LimitStringLength, len(t.Value), t.Pos); err != nil {
+            return nil, err
+        }
+        obj = xtype.NewStringObject(t.Value)
+        if p.opts.Interpolation != nil && (t.Type == lexer.BasicString || t.Type == lexer.MultiLineBasicString) {
+            var err error
+            if obj, err = p.interpolate(t); err != nil {
+                return nil, err
+            }
+        }
+        if p.opts.Decrypt != nil && obj.Type == xtype.TypeString {
+            var err error
+            if obj, err = p.decrypt(t.Pos, obj); err != nil {
+                return nil, err
+            }
+        }
+    case t.Type == lexer.Variable:
+        var err error
+        if obj, err = p.interpolate(t); err != nil {
+            return nil, err
+        }
+    case t.Type == lexer.Bare:
+        obj = scalarObject(t.Value)
+        if obj == nil {
+            if p.secret() {
+                return nil, p.errorf(t.Pos, "invalid value %s", secretMask)
+            }
+            return nil, p.errorf(t.Pos, "invalid value %s", t.Value)
+        }
+        // 整数必须在int64范围内
+        if obj.Type == xtype.TypeNumber && !util.IsIntInRange(t.Value) {
+            if p.secret() {
+                return nil, p.errorf(t.Pos, "integer %s is out of range", secretMask)
+            }
+            return nil, p.errorf(t.Pos, "integer %s is out of range", t.Value)
+        }
+    case t.Type == lexer.LeftBracket:
+        if err := p.enter(t.Pos); err != nil {
+            return nil, err
+        }
+        m, err := p.parseArray()
+        p.depth--
         if err != nil {
             return nil, err
         }
-        return xtype.NewMapObject(arr), nil
-    }
-    // Inline table (normalized)
-    if chars[0] == '{' && chars[charsSize-1] == '}' {
-        arr, err := parseInlineTable(chars)
+        p.static[m] = true
+        obj = xtype.NewMapObject(m)
+    case t.Type == lexer.LeftBrace:
+        if err := p.enter(t.Pos); err != nil {
+            return nil, err
+        }
+        m, err := p.parseInlineTable()
+        p.depth--
         if err != nil {
             return nil, err
         }
-        return xtype.NewMapObject(arr), nil
+        p.static[m] = true
+        obj = xtype.NewMapObject(m)
+    default:
+        return nil, p.unexpected(t, "value")
     }
-    return nil, errors.New("Unknown value type: " + val)
+    obj.Pos = t.Pos
+    return obj, nil
 }
 
-// parseArray 解析数组
-func parseArray(chars []rune) (*xtype.Map, error) {
-    openBrackets := 0
-    openString := false
-    openCurlyBraces := 0
-    openLString := false
-    buffer := ""
+// parseArray 解析数组，数组中可以包含换行以及注释，允许以逗号结尾
+// array = '[' [ value { ',' value } [ ',' ] ] ']'
+func (p *parser) parseArray() (*xtype.Map, error) {
+    arr := xtype.NewArrayMap()
+    for {
+        p.skipNewlines(lexer.ModeValue)
+        if p.lex.Peek(lexer.ModeValue).Type == lexer.RightBracket {
+            p.lex.Next(lexer.ModeValue)
+            return arr, nil
+        }
+        obj, err := p.parseValue()
+        if err != nil {
+            return nil, err
+        }
+        k := xtype.NewNumberKey(strconv.Itoa(len(arr.Keys)))
+        k.Pos = obj.Pos
+        arr.Add(k, obj)
 
-    charsSize := len(chars)
-    arr := xtype.NewMap()
-    keyPos := 0
-    for i := 0; i < charsSize; i++ {
-        if chars[i] == '[' && !openString && !openLString {
-            openBrackets++
-            if openBrackets == 1 {
-                continue
-            }
-        } else if chars[i] == ']' && !openString && !openLString {
-            openBrackets--
-            if openBrackets == 0 {
-                if strings.TrimSpace(buffer) != "" {
-                    obj, err := ParseSingle(strings.TrimSpace(buffer))
-                    if err != nil {
-                        return nil, err
-                    }
-                    arr.Add(xtype.NewNumberKey(strconv.Itoa(keyPos)), obj)
-                    keyPos++
-                }
-                return arr, nil
-            }
-        } else if chars[i] == '"' && ((i == 0) || (i > 0 && chars[i-1] != '\\')) && !openLString {
-            openString = !openString
-        } else if chars[i] == '\'' && !openString {
-            openLString = !openLString
-        } else if chars[i] == '{' && !openString && !openLString {
-            openCurlyBraces++
-        } else if chars[i] == '}' && !openString && !openLString {
-            openCurlyBraces--
-        }
-
-        if (chars[i] == ',' || chars[i] == '}') && !openString && !openLString && openBrackets == 1 && openCurlyBraces == 0 {
-            if chars[i] == '}' {
-                buffer += string(chars[i])
-            }
-            buffer = strings.TrimSpace(buffer)
-            if buffer != "" {
-                obj, err := ParseSingle(strings.TrimSpace(buffer))
-                if err != nil {
-                    return nil, err
-                }
-                arr.Add(xtype.NewNumberKey(strconv.Itoa(keyPos)), obj)
-                keyPos++
-            }
-            buffer = ""
-        } else {
-            buffer += string(chars[i])
+        p.skipNewlines(lexer.ModeValue)
+        switch t := p.lex.Next(lexer.ModeValue); t.Type {
+        case lexer.Comma:
+        case lexer.RightBracket:
+            return arr, nil
+        default:
+            return nil, p.unexpected(t, "',' or ']'")
         }
     }
-    return nil, errors.New("Wrong array definition:" + string(chars))
 }
 
-// parseInlineTable Parse inline tables into common table array
-func parseInlineTable(chars []rune) (*xtype.Map, error) {
-    charsSize := len(chars)
-    if chars[0] == '{' && chars[charsSize-1] == '}' {
-        chars = chars[1 : charsSize-1]
-    } else {
-        return nil, errors.New("invalid inline table definition: " + string(chars))
+// parseInlineTable 解析行内表，与数组一样允许换行
+// inline-table = '{' [ keyval { ',' keyval } ] '}'
+func (p *parser) parseInlineTable() (*xtype.Map, error) {
+    m := xtype.NewMap()
+    for {
+        p.skipNewlines(lexer.ModeKey)
+        if p.lex.Peek(lexer.ModeKey).Type == lexer.RightBrace {
+            p.lex.Next(lexer.ModeKey)
+            return m, nil
+        }
+        parent := p.path
+        if err := p.parseKeyValue(m); err != nil {
+            return nil, err
+        }
+        p.path = parent
+        p.skipNewlines(lexer.ModeKey)
+        switch t := p.lex.Next(lexer.ModeKey); t.Type {
+        case lexer.Comma:
+        case lexer.RightBrace:
+            return m, nil
+        default:
+            return nil, p.unexpected(t, "',' or '}'")
+        }
     }
+}
 
-    charsSize = len(chars)
-    openString := false
-    openLString := false
-    openBrackets := 0
-    buf := bytes.Buffer{}
+// expectLineEnd 键值对以及表头之后只能是换行或者文件结尾
+func (p *parser) expectLineEnd() error {
+    t := p.lex.Next(lexer.ModeKey)
+    if t.Type != lexer.Newline && t.Type != lexer.EOF {
+        return p.unexpected(t, "newline")
+    }
+    return nil
+}
 
-    arr := xtype.NewMap()
-    for i := 0; i < charsSize; i++ {
-        if chars[i] == '"' && chars[i-1] != '\\' {
-            openString = !openString
-        } else if chars[i] == '\'' {
-            openLString = !openLString
-        } else if chars[i] == '[' && !openString && !openLString {
-            openBrackets++
-        } else if chars[i] == ']' && !openString && !openLString {
-            openBrackets--
-        }
+func (p *parser) skipNewlines(mode int) {
+    for p.lex.Peek(mode).Type == lexer.Newline {
+        p.lex.Next(mode)
+    }
+}
 
-        if chars[i] == ',' && !openString && !openLString && openBrackets == 0 {
-            obj, err := parseInlineTableFieldValue(buf.String())
-            if err != nil {
-                return nil, err
-            }
-            arr.Merge(obj)
-            // keyPos++
-            buf.Reset()
-        } else {
-            buf.WriteRune(chars[i])
+// enter 进入一层数组或者行内表，并检查嵌套层数
+func (p *parser) enter(pos xtype.Position) error {
+    p.depth++
+    if p.opts.Limits == nil || p.opts.Limits.MaxDepth <= 0 {
+        if p.depth > maxNestingDepth {
+            return &formatter.LimitError{Limit: formatter.LimitDepth, Max: maxNestingDepth, File: pos.File, Line: pos.Line, Column: pos.Column}
         }
+        return nil
     }
+    return p.checkLimit(formatter.LimitDepth, p.depth, pos)
+}
 
-    // parse last buffer
-    obj, err := parseInlineTableFieldValue(buf.String())
-    if err != nil {
-        return nil, err
+// checkLimit 检查资源限制，超出时返回记录了位置的*formatter.LimitError
+func (p *parser) checkLimit(limit string, n int, pos xtype.Position) error {
+    err := p.opts.Limits.Check(limit, n, pos.Line, pos.Column)
+    if le, ok := err.(*formatter.LimitError); ok {
+        le.File = pos.File
     }
-    arr.Merge(obj)
-    // keyPos++
-    return arr, nil
+    return err
+}
+
+func (p *parser) errorf(pos xtype.Position, format string, args ...interface{}) error {
+    return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
 }
 
-// parseInlineTableFieldValue 解析键值对内容
-func parseInlineTableFieldValue(snippet string) (*xtype.Map, error) {
-    pos := strings.Index(snippet, "=")
-    if pos <= 0 {
-        return nil, errors.New("[split] invalid inline toml table data: " + snippet)
+// decrypt 解密字符串值，不是加密的值时原样返回
+func (p *parser) decrypt(pos xtype.Position, obj *xtype.Object) (*xtype.Object, error) {
+    literal, ok, err := p.opts.Decrypt(p.path, util.String(obj.Value))
+    if err != nil {
+        return nil, p.errorf(pos, "%s", err)
+    }
+    if !ok {
+        return obj, nil
     }
-    field := strings.TrimSpace(snippet[0:pos])
-    val := strings.TrimSpace(snippet[pos+1:])
-    obj, err := ParseSingle(val)
+    v, err := newParser(literal, &Options{Limits: p.opts.Limits}).parseSingle()
     if err != nil {
-        return nil, errors.New("[parse] invalid inline toml table data: " + val + " <= " + snippet)
-    }
-    arr := xtype.NewMap()
-    arr.DeepAdd([]string{field}, obj)
-    return arr, nil
-}
-
-// parseTomlTableName Parses TOML table names and returns the hierarchy array of table names.
-func parseTomlTableName(chars []rune) []string {
-    buffer := bytes.Buffer{}
-    strOpen := false
-    strOpenChar := '"'
-    names := make([]string, 0)
-
-    charsSize := len(chars)
-    for i := 0; i < charsSize; i++ {
-        if chars[i] == '"' {
-            if !strOpen || (strOpen && chars[i-1] != '\\' && strOpenChar == '"') {
-                strOpen = !strOpen
-                if strOpen {
-                    strOpenChar = '"'
-                }
-                continue
-            }
-        } else if chars[i] == '\'' {
-            if !strOpen || (strOpen && chars[i-1] != '\\' && strOpenChar == '\'') {
-                strOpen = !strOpen
-                if strOpen {
-                    strOpenChar = '\''
-                }
-                continue
-            }
-        } else if chars[i] == '.' && !strOpen {
-            names = append(names, buffer.String())
-            buffer.Reset()
-            continue
+        return nil, p.errorf(pos, "decrypted value is not a valid toml value")
+    }
+    return v, nil
+}
+
+// unexpected 生成遇到非预期记号的错误，词法错误直接使用其错误信息
+// 值是秘密时不引用记号的内容，词法错误只保留引用内容之前的说明
+func (p *parser) unexpected(t lexer.Token, expected string) error {
+    p.lineEnded = t.Type == lexer.Newline || t.Type == lexer.EOF
+    secret := p.secret()
+    if t.Type == lexer.Error {
+        msg := t.Value
+        if i := strings.IndexAny(msg, "\\'"); secret && i > 0 {
+            msg = strings.TrimSpace(msg[:i])
         }
-        buffer.WriteRune(chars[i])
+        return p.errorf(t.Pos, "%s", msg)
     }
-    if buffer.Len() > 0 {
-        names = append(names, buffer.String())
+    if secret && t.Type != lexer.Newline && t.Type != lexer.EOF {
+        return p.errorf(t.Pos, "expected %s, got %s", expected, strconv.Quote(secretMask))
+    }
+    return p.errorf(t.Pos, "expected %s, got %s", expected, t)
+}
+
+// secretMask 错误信息中代替秘密的内容
+const secretMask = "***"
+
+// secret 判断正在解析的值是否是秘密
+func (p *parser) secret() bool {
+    return p.opts.Secret != nil && len(p.path) > 0 && p.opts.Secret(p.path)
+}
+
+// keyNames 在键路径之后追加键名，返回新的切片
+func keyNames(path []string, keys []lexer.Token) []string {
+    names := make([]string, 0, len(path)+len(keys))
+    names = append(names, path...)
+    for _, k := range keys {
+        names = append(names, k.Value)
     }
     return names
 }
 
-// parseKeyValue 解析键值对
-func parseKeyValue(arr *xtype.Map, keys []string, val string) error {
-    val = strings.TrimSpace(val)
-    obj, err := ParseSingle(val)
-    if err != nil {
-        return err
+// scalarObject 识别布尔值、数字以及日期时间，无法识别时返回nil
+func scalarObject(val string) *xtype.Object {
+    if val == "true" || val == "false" {
+        return xtype.NewBoolObject(val)
+    }
+    if util.IsNumeric(val) {
+        return xtype.NewNumberObject(val)
+    }
+    if util.IsDatetime(val) {
+        return xtype.NewDatetimeObject(val)
     }
-    arr.DeepAdd(keys, obj)
     return nil
 }
+
+// lastTable 获取表数组的最后一个元素
+func lastTable(arr *xtype.Map) *xtype.Map {
+    return arr.Data[arr.Keys[len(arr.Keys)-1]].Value.(*xtype.Map)
+}
+
+// newKey 根据记号创建键，数字键名与数组下标使用相同的键类型
+func newKey(t lexer.Token) *xtype.Key {
+    k := xtype.NewStringKey(t.Value)
+    if util.IsPositiveIntNumeric(t.Value) {
+        k = xtype.NewNumberKey(t.Value)
+    }
+    k.Pos = t.Pos
+    return k
+}
+
+func newMapObject(m *xtype.Map, pos xtype.Position) *xtype.Object {
+    obj := xtype.NewMapObject(m)
+    obj.Pos = pos
+    return obj
+}
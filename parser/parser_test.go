@@ -3,9 +3,13 @@ package parser
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/whencome/toml2x/formatter"
+	"github.com/whencome/toml2x/xtype"
 )
 
 func TestNormalize(t *testing.T) {
@@ -48,9 +52,9 @@ func TestParseInlineTable(t *testing.T) {
   {title = "Games", url = "/games", childs = [{title = "Game A", url = "/games/game-a", childs = []}, {title = "Game B", url = "/games/game-b", childs = []}]},
   {title = "About us", url = "/about", childs = []}
 ]`
-	parsed, err := parseInlineTableFieldValue(tomlInlineTable)
+	parsed, err := ParseTable(tomlInlineTable)
 	if err != nil {
-		t.Logf("parseInlineTableFieldValue failed: %s \n", err)
+		t.Logf("TestParseInlineTable failed: %s \n", err)
 		t.Fail()
 		return
 	}
@@ -126,3 +130,378 @@ func TestParseTable(t *testing.T) {
 
 	t.Logf("%+v\n", rs)
 }
+
+func TestPositions(t *testing.T) {
+	toml := `# servers
+title = "demo"
+
+[servers.alpha]
+  ip = "10.0.0.1"
+  ports = [ 8000,
+    8001 ]
+  dc = "eqdc10"
+[[products]]
+name = { first = "Tom" }`
+	toml, err := formatter.Normalize(toml)
+	if err != nil {
+		t.Logf("normalize toml failed: %s\n", err)
+		t.Fail()
+		return
+	}
+	rs, err := ParseWithOptions("table", toml, &Options{File: "demo.toml"})
+	if err != nil {
+		t.Logf("parse table failed: %s\n", err)
+		t.Fail()
+		return
+	}
+	root := rs.Value.(*xtype.Map)
+	servers := root.Data[root.GetKey("servers")]
+	alpha := servers.Value.(*xtype.Map).Data[servers.Value.(*xtype.Map).GetKey("alpha")].Value.(*xtype.Map)
+	products := root.Data[root.GetKey("products")].Value.(*xtype.Map)
+	product := products.Data[products.GetKey("0")].Value.(*xtype.Map)
+	name := product.Data[product.GetKey("name")].Value.(*xtype.Map)
+	cases := []struct {
+		actual   xtype.Position
+		expected string
+	}{
+		{root.KeyPosition("title"), "demo.toml:2:1"},
+		{root.Data[root.GetKey("title")].Position(), "demo.toml:2:9"},
+		{root.KeyPosition("servers"), "demo.toml:4:1"},
+		{alpha.KeyPosition("ip"), "demo.toml:5:3"},
+		{alpha.Data[alpha.GetKey("ip")].Position(), "demo.toml:5:8"},
+		{alpha.Data[alpha.GetKey("ports")].Position(), "demo.toml:6:11"},
+		{alpha.KeyPosition("dc"), "demo.toml:8:3"},
+		{products.KeyPosition("0"), "demo.toml:9:1"},
+		{name.KeyPosition("first"), "demo.toml:10:10"},
+		{name.Data[name.GetKey("first")].Position(), "demo.toml:10:18"},
+	}
+	for i, c := range cases {
+		if c.actual.String() != c.expected {
+			t.Logf("case %d: expected position %s, got %s\n", i, c.expected, c.actual)
+			t.Fail()
+		}
+	}
+}
+
+func benchmarkParseTable(b *testing.B, size int) {
+	tomlBytes, err := ioutil.ReadFile("example.toml")
+	if err != nil {
+		b.Fatalf("read file content failed: %s\n", err)
+	}
+	buf := strings.Builder{}
+	for buf.Len() < size {
+		buf.Write(tomlBytes)
+		buf.WriteString("\n")
+	}
+	toml, err := formatter.Normalize(buf.String())
+	if err != nil {
+		b.Fatalf("normalize toml failed: %s\n", err)
+	}
+	b.SetBytes(int64(len(toml)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseTable(toml); err != nil {
+			b.Fatalf("parse table failed: %s\n", err)
+		}
+	}
+}
+
+func BenchmarkParseTable1MB(b *testing.B) {
+	benchmarkParseTable(b, 1<<20)
+}
+
+func BenchmarkParseTable10MB(b *testing.B) {
+	benchmarkParseTable(b, 10<<20)
+}
+
+func TestLimits(t *testing.T) {
+	limits := &formatter.Limits{
+		MaxDocumentSize: 200,
+		MaxDepth:        3,
+		MaxKeys:         5,
+		MaxStringLength: 10,
+		MaxArrayTables:  2,
+	}
+	cases := []struct {
+		toml  string
+		limit string
+		pos   string
+	}{
+		{strings.Repeat("# padding\n", 30), formatter.LimitDocumentSize, "limits.toml: "},
+		{"a = [[[[1]]]]", formatter.LimitDepth, "limits.toml:1:8: "},
+		{"a = { b = [{ c = [1] }] }", formatter.LimitDepth, "limits.toml:1:18: "},
+		{"a = 1\nb = 2\n[c]\nd.e = 3\nf = 4", formatter.LimitKeys, "limits.toml:5:1: "},
+		{"a = \"0123456789ab\"", formatter.LimitStringLength, "limits.toml:1:5: "},
+		{"'0123456789ab' = 1", formatter.LimitStringLength, "limits.toml:1:1: "},
+		{"[[a]]\n[[a]]\n[[b]]", formatter.LimitArrayTables, "limits.toml:3:1: "},
+	}
+	for _, c := range cases {
+		_, err := ParseWithOptions("table", c.toml, &Options{File: "limits.toml", Limits: limits})
+		le, ok := err.(*formatter.LimitError)
+		if !ok || le.Limit != c.limit || !strings.HasPrefix(err.Error(), c.pos) {
+			t.Logf("parse %q: expected %s exceeded at %s, got %v\n", c.toml, c.limit, c.pos, err)
+			t.Fail()
+		}
+	}
+	if _, err := ParseWithOptions("table", "a = [[1, 2], [3]]\nb = 'short'", &Options{Limits: limits}); err != nil {
+		t.Logf("parse within limits failed: %s\n", err)
+		t.Fail()
+	}
+
+	// 未设置限制时也不能因为嵌套过深而耗尽栈空间
+	deep := "a = " + strings.Repeat("[", 100000) + strings.Repeat("]", 100000)
+	_, err := ParseTable(deep)
+	if le, ok := err.(*formatter.LimitError); !ok || le.Limit != formatter.LimitDepth {
+		t.Logf("expected MaxDepth exceeded for deeply nested arrays, got %v\n", err)
+		t.Fail()
+	}
+	if _, err := ParseSingle(strings.Repeat("{a=", 5000) + "1" + strings.Repeat("}", 5000)); err == nil {
+		t.Log("expected error for deeply nested inline tables\n")
+		t.Fail()
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	vars := map[string]string{"DB_HOST": "db.example.com", "PORT": "15432", "EMPTY": "", "QUOTE": "a\"b"}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	toml := `host = "${DB_HOST}:${PORT:-5432}"
+price = "$$5 and $HOME"
+literal = '${DB_HOST}'
+empty = "${EMPTY:-default}"
+quote = "${QUOTE}"
+multi = """
+${DB_HOST}"""
+port = ${PORT}
+timeout = ${TIMEOUT:-30}
+name = ${DB_HOST}
+list = [${PORT}, "${DB_HOST}"]
+`
+	expected := `{"host":"db.example.com:15432","price":"$5 and $HOME","literal":"${DB_HOST}","empty":"default","quote":"a\"b","multi":"db.example.com","port":"15432","timeout":"30","name":"db.example.com","list":["15432","db.example.com"]}`
+	obj, err := ParseWithOptions("table", toml, &Options{Interpolation: &Interpolation{Lookup: lookup}})
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	if rs := obj.Json(true); rs != expected {
+		t.Logf("expected\n%s\ngot\n%s\n", expected, rs)
+		t.Fail()
+	}
+
+	obj, err = ParseWithOptions("table", toml, &Options{Interpolation: &Interpolation{Lookup: lookup, Coerce: true}})
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	if rs := obj.Json(true); !strings.Contains(rs, `"port":15432,"timeout":30,"name":"db.example.com","list":[15432,`) {
+		t.Logf("unquoted values should be coerced, got %s\n", rs)
+		t.Fail()
+	}
+
+	errors := map[string]string{
+		"a = 'x'\nb = \"x ${MISSING}\"":   "app.toml:2:8: variable MISSING is not set",
+		"a = ${MISSING}":                  "app.toml:1:5: variable MISSING is not set",
+		"a = \"${1ABC}\"":                 "app.toml:1:6: invalid variable name \"1ABC\"",
+		"a = \"${PORT\"":                  "app.toml:1:6: unterminated variable reference",
+		"a = ${PORT\nb = 1":               "app.toml:1:5: unterminated variable reference",
+		"a = \"\"\"\nx\n${MISSING}\"\"\"": "app.toml:1:5: variable MISSING is not set",
+	}
+	for toml, msg := range errors {
+		_, err := ParseWithOptions("table", toml, &Options{File: "app.toml", Interpolation: &Interpolation{Lookup: lookup}})
+		if _, ok := err.(*SyntaxError); !ok || err.Error() != msg {
+			t.Logf("parse %q: expected %s, got %v\n", toml, msg, err)
+			t.Fail()
+		}
+	}
+
+	// 未开启时保持原样，未加引号的引用是语法错误
+	if obj, err := ParseTable("a = \"${DB_HOST}\""); err != nil || obj.Json(true) != `{"a":"${DB_HOST}"}` {
+		t.Logf("interpolation should be opt-in, got %v %v\n", obj, err)
+		t.Fail()
+	}
+	if _, err := ParseTable("a = ${DB_HOST}"); err == nil {
+		t.Log("expected error for unquoted variable without interpolation\n")
+		t.Fail()
+	}
+}
+
+func TestInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/app.toml":           {Data: []byte("include = ['common/base.toml', 'common/log.toml']\nname = 'app'\n[db]\nhost = 'db.app'\n")},
+		"conf/common/base.toml":   {Data: []byte("include = 'ports.toml'\nname = 'base'\n[db]\nhost = 'localhost'\nport = 5432\n")},
+		"conf/common/ports.toml":  {Data: []byte("ports = [80, 443]\n")},
+		"conf/common/log.toml":    {Data: []byte("[log]\nlevel = 'info'\n")},
+		"conf/cycle.toml":         {Data: []byte("include = 'cycle2.toml'\n")},
+		"conf/cycle2.toml":        {Data: []byte("a = 1\ninclude = ['common/log.toml', 'cycle.toml']\n")},
+		"conf/bad.toml":           {Data: []byte("include = 'common/broken.toml'\n")},
+		"conf/common/broken.toml": {Data: []byte("a = 1\nb = \n")},
+		"conf/missing.toml":       {Data: []byte("a = 1\ninclude = 'nothing.toml'\n")},
+		"conf/escape.toml":        {Data: []byte("include = '../../etc/passwd'\n")},
+		"conf/type.toml":          {Data: []byte("include = [1]\n")},
+		"conf/abs.toml":           {Data: []byte("include = '/etc/passwd'\n")},
+		"conf/svc/api.toml":       {Data: []byte("include = '../common/log.toml'\nname = 'api'\n")},
+	}
+	opts := &Options{File: "conf/app.toml", Include: &Include{FS: fsys}}
+	obj, err := ParseWithOptions("table", string(fsys["conf/app.toml"].Data), opts)
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	expected := `{"ports":[80,443],"name":"app","db":{"host":"db.app","port":5432},"log":{"level":"info"}}`
+	if rs := obj.Json(true); rs != expected {
+		t.Logf("expected\n%s\ngot\n%s\n", expected, rs)
+		t.Fail()
+	}
+	if port, _ := obj.Get("db.port"); port.Pos.String() != "conf/common/base.toml:5:8" {
+		t.Logf("included values should keep their position, got %s\n", port.Pos)
+		t.Fail()
+	}
+
+	errors := map[string]string{
+		"conf/cycle.toml":   "conf/cycle2.toml:2:31: include cycle: conf/cycle.toml -> conf/cycle2.toml -> conf/cycle.toml",
+		"conf/bad.toml":     "conf/common/broken.toml:2:5: expected value, got newline",
+		"conf/missing.toml": "conf/missing.toml:2:11: include nothing.toml: file does not exist",
+		"conf/escape.toml":  "conf/escape.toml:1:11: invalid include path ../../etc/passwd: outside the include root",
+		"conf/abs.toml":     "conf/abs.toml:1:11: invalid include path /etc/passwd: absolute paths are not allowed",
+		"conf/type.toml":    "conf/type.toml:1:12: include must be a string or an array of strings",
+	}
+	for name, msg := range errors {
+		_, err := ParseWithOptions("table", string(fsys[name].Data), &Options{File: name, Include: &Include{FS: fsys}})
+		if err == nil || err.Error() != msg {
+			t.Logf("parse %s: expected %s, got %v\n", name, msg, err)
+			t.Fail()
+		}
+		errs := Validate(string(fsys[name].Data), &Options{File: name, Include: &Include{FS: fsys}})
+		if len(errs) != 1 || errs[0].Error() != msg {
+			t.Logf("validate %s: expected %s, got %v\n", name, msg, errs)
+			t.Fail()
+		}
+	}
+
+	// 可以包含上级目录中的文件
+	obj, err = ParseWithOptions("table", string(fsys["conf/svc/api.toml"].Data), &Options{File: "conf/svc/api.toml", Include: &Include{FS: fsys}})
+	if err != nil || obj.Json(true) != `{"log":{"level":"info"},"name":"api"}` {
+		t.Logf("parent include: unexpected %v %v\n", obj, err)
+		t.Fail()
+	}
+
+	// 资源限制对整个包含树生效
+	limits := map[string]*formatter.Limits{
+		"conf/common/base.toml:3:1: MaxKeys exceeded: limit is 6":       {MaxKeys: 6},
+		"conf/common/base.toml: MaxDocumentSize exceeded: limit is 150": {MaxDocumentSize: 150},
+		"conf/common/base.toml:1:11: MaxDepth exceeded: limit is 2":     {MaxDepth: 2},
+	}
+	for msg, l := range limits {
+		_, err := ParseWithOptions("table", string(fsys["conf/app.toml"].Data), &Options{File: "conf/app.toml", Limits: l, Include: &Include{FS: fsys}})
+		if err == nil || err.Error() != msg {
+			t.Logf("limits %+v: expected %s, got %v\n", l, msg, err)
+			t.Fail()
+		}
+	}
+
+	// 未开启时include是普通的键
+	if obj, err := ParseTable("include = 'a.toml'"); err != nil || obj.Json(true) != `{"include":"a.toml"}` {
+		t.Logf("include should be opt-in, got %v %v\n", obj, err)
+		t.Fail()
+	}
+}
+
+func TestIncludeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml2x")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %s\n", err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "common.toml"), []byte("[server]\nport = 80\nhost = \n"), 0644)
+	file := filepath.Join(dir, "app.toml")
+	_, err = ParseWithOptions("table", "import = 'common.toml'\n", &Options{File: file, Include: &Include{Key: "import"}})
+	if err == nil || err.Error() != filepath.Join(dir, "common.toml")+":3:8: expected value, got newline" {
+		t.Logf("errors in included files should name the file, got %v\n", err)
+		t.Fail()
+	}
+
+	// 根目录内的其他目录中的文件
+	os.MkdirAll(filepath.Join(dir, "shared"), 0755)
+	os.MkdirAll(filepath.Join(dir, "app"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "shared", "log.toml"), []byte("level = 'info'\n"), 0644)
+	file = filepath.Join(dir, "app", "app.toml")
+	doc := "include = '../shared/log.toml'\n"
+	if obj, err := ParseWithOptions("table", doc, &Options{File: file, Include: &Include{Root: dir}}); err != nil || obj.Json(true) != `{"level":"info"}` {
+		t.Logf("include with root: unexpected %v %v\n", obj, err)
+		t.Fail()
+	}
+	if _, err := ParseWithOptions("table", doc, &Options{File: file, Include: &Include{}}); err == nil || err.Error() != file+":1:11: invalid include path ../shared/log.toml: outside the include root" {
+		t.Logf("include without root: unexpected %v\n", err)
+		t.Fail()
+	}
+	root := filepath.Join(dir, "shared")
+	if _, err := ParseWithOptions("table", doc, &Options{File: file, Include: &Include{Root: root}}); err == nil || err.Error() != file+": the file is outside the include root "+root {
+		t.Logf("file outside root: unexpected %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	secret := func(keys []string) bool {
+		return strings.Contains(strings.Join(keys, "."), "password")
+	}
+	cases := map[string]string{
+		"password = hunter2\n":                  "1:12: invalid value ***",
+		"[db]\npassword = 'hunter2' x\n":        "2:22: expected newline, got \"***\"",
+		"db = { password = \"a\\qb\" }\n":       "1:19: invalid escape sequence",
+		"db = { password = 'a', user = bob }\n": "1:31: invalid value bob",
+		"[password]\nkey = \"x\" y\n":           "2:11: expected newline, got \"***\"",
+		"name = bob\n":                          "1:8: invalid value bob",
+		"password = 0xFFFFFFFFFFFFFFFF\n":       "1:12: integer *** is out of range",
+	}
+	for doc, expected := range cases {
+		_, err := ParseWithOptions("table", doc, &Options{Secret: secret})
+		if err == nil || err.Error() != expected {
+			t.Logf("parse %q: expected %s, got %v\n", doc, expected, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestStaticValues(t *testing.T) {
+	cases := map[string]string{
+		"a = [1, 2]\n[a.b]\n":              "2:2: key a is already defined as an array",
+		"a = [1, 2]\na.b = 1\n":            "2:1: key a is already defined as an array",
+		"a = []\n[[a]]\n":                  "2:3: key a is already defined as an array",
+		"a = { x = 1 }\na.y = 2\n":         "2:1: key a is already defined as an inline table",
+		"a = { x = 1 }\n[a]\n":             "2:2: key a is already defined as an inline table",
+		"a = { x = 1 }\n[a.b]\n":           "2:2: key a is already defined as an inline table",
+		"a = { b = { x = 1 }, b.y = 2 }\n": "1:22: key b is already defined as an inline table",
+		"[t]\nb = { x = 1 }\n[t.b.c]\n":    "3:4: key b is already defined as an inline table",
+		"a = [{ x = 1 }]\n[a.b]\n":         "2:2: key a is already defined as an array",
+	}
+	for doc, expected := range cases {
+		_, err := ParseWithOptions("table", doc, nil)
+		if err == nil || err.Error() != expected {
+			t.Logf("parse %q: expected %s, got %v\n", doc, expected, err)
+			t.Fail()
+		}
+	}
+	if rs, err := ParseWithOptions("table", "a = { b.x = 1, b.y = 2 }\n[t]\nc.d = 1\nc.e = 2\n", nil); err != nil || rs.Json(true) != `{"a":{"b":{"x":1,"y":2}},"t":{"c":{"d":1,"e":2}}}` {
+		t.Logf("dotted keys: unexpected %v %v\n", rs, err)
+		t.Fail()
+	}
+}
+
+func TestIntegerRange(t *testing.T) {
+	cases := map[string]string{
+		"a = 0xFFFFFFFFFFFFFFFF\n":       "1:5: integer 0xFFFFFFFFFFFFFFFF is out of range",
+		"a = [1, 9223372036854775808]\n": "1:9: integer 9223372036854775808 is out of range",
+	}
+	for doc, expected := range cases {
+		_, err := ParseWithOptions("table", doc, nil)
+		if err == nil || err.Error() != expected {
+			t.Logf("parse %q: expected %s, got %v\n", doc, expected, err)
+			t.Fail()
+		}
+	}
+	if rs, err := ParseWithOptions("table", "a = -9223372036854775808\nb = 0x7FFFFFFFFFFFFFFF\n", nil); err != nil || rs.Json(true) != `{"a":-9223372036854775808,"b":9223372036854775807}` {
+		t.Logf("int64 bounds: unexpected %v %v\n", rs, err)
+		t.Fail()
+	}
+}
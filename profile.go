@@ -0,0 +1,105 @@
+package toml2x
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/merge"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// DefaultProfileKeys 默认存放配置方案的表，如 [profile.production]、[env.staging]
+var DefaultProfileKeys = []string{"profile", "env"}
+
+// ApplyProfile 使用指定的配置方案覆盖基础配置，返回新的对象，obj不会被修改
+// keys 存放配置方案的表，为空时使用DefaultProfileKeys；其中的子表都是配置方案，这些表会从结果中删除，
+// 只包含普通值的同名表（如 [env] 中的 PATH）按普通的表保留，同时包含子表以及普通值时返回错误；
+// 选中的方案与基础配置按表递归合并，其他值（包括数组）整体替换；方案不存在时返回错误
+func ApplyProfile(obj *xtype.Object, profile string, keys []string) (*xtype.Object, error) {
+    m, ok := obj.Value.(*xtype.Map)
+    if !ok || m.IsArray() {
+        return nil, fmt.Errorf("profile %s: the document is not a table", profile)
+    }
+    if len(keys) == 0 {
+        keys = DefaultProfileKeys
+    }
+    base := xtype.NewMap()
+    overlays := make([]merge.Layer, 0)
+    available := make([]string, 0)
+    for _, k := range m.Keys {
+        v := m.Data[k]
+        if !isProfileKey(k.Value, keys) || !isTable(v) {
+            base.Add(k, v)
+            continue
+        }
+        container := v.Value.(*xtype.Map)
+        isProfiles, err := profileContainer(k.Value, container)
+        if err != nil {
+            return nil, err
+        }
+        if !isProfiles {
+            base.Add(k, v)
+            continue
+        }
+        for _, pk := range container.Keys {
+            available = append(available, k.Value+"."+pk.Value)
+        }
+        if pk := container.GetKey(profile); pk != nil {
+            if !isTable(container.Data[pk]) {
+                return nil, fmt.Errorf("%s: profile %s.%s is not a table", container.Data[pk].Pos, k.Value, profile)
+            }
+            overlays = append(overlays, merge.Layer{Name: k.Value + "." + profile, Data: container.Data[pk]})
+        }
+    }
+    if len(overlays) == 0 {
+        sort.Strings(available)
+        if len(available) == 0 {
+            return nil, fmt.Errorf("profile %s not found, the document has no profiles", strconv.Quote(profile))
+        }
+        return nil, fmt.Errorf("profile %s not found, available: %s", strconv.Quote(profile), strings.Join(available, ", "))
+    }
+    layers := append([]merge.Layer{{Name: "base", Data: &xtype.Object{Value: base, Type: obj.Type, Pos: obj.Pos}}}, overlays...)
+    rs, err := merge.Merge(layers, nil)
+    if err != nil {
+        return nil, err
+    }
+    return rs.Data, nil
+}
+
+// profileContainer 判断表中是否存放配置方案，子表与普通值不能混合
+func profileContainer(name string, container *xtype.Map) (bool, error) {
+    var table, value *xtype.Key
+    for _, k := range container.Keys {
+        if isTable(container.Data[k]) {
+            if table == nil {
+                table = k
+            }
+        } else if value == nil {
+            value = k
+        }
+    }
+    if table != nil && value != nil {
+        return false, fmt.Errorf("%s: %s.%s is not a profile table, %s can not mix profiles (such as %s.%s) and values",
+            container.Data[value].Pos, name, value.Value, name, name, table.Value)
+    }
+    return table != nil, nil
+}
+
+func isProfileKey(k string, keys []string) bool {
+    for _, key := range keys {
+        if k == key {
+            return true
+        }
+    }
+    return false
+}
+
+// isTable 判断对象是否是表，空的Map视为表
+func isTable(o *xtype.Object) bool {
+    if o == nil || o.Type != xtype.TypeMap {
+        return false
+    }
+    return !o.Value.(*xtype.Map).IsArray()
+}
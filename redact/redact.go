@@ -0,0 +1,167 @@
+/**
+ * redaction of secret values in parsed toml documents.
+ * a value is secret when its key path matches one of the patterns or when its schema is tagged with secret = true,
+ * secret values are replaced with "***" or with a short keyed hash (HMAC-SHA256) that still allows comparing them.
+ */
+package redact
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "path"
+    "strings"
+    "sync"
+
+    "github.com/whencome/toml2x/schema"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Mask 代替秘密的掩码
+const Mask = "***"
+
+// DefaultPatterns Patterns为nil时使用的匹配模式
+var DefaultPatterns = []string{"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*", "*private_key*"}
+
+// Options 隐藏秘密的选项
+type Options struct {
+    // 键路径的匹配模式，语法见path.Match，不区分大小写；路径是以点号连接的键名，不包含数组下标，
+    // 如 db.password、users.token，表匹配时表中所有的值都是秘密；为nil时使用DefaultPatterns，不使用模式时设置为空的切片
+    Patterns []string
+    // 标记了secret的值是秘密，为nil时只按Patterns判断
+    Schema *schema.Schema
+    // 为true时使用值的HMAC-SHA256的前16位代替掩码，相同的值得到相同的结果，用于判断秘密是否相同或者是否改变，
+    // 不能用于保存秘密
+    Hash bool
+    // 计算HMAC的密钥，为空时使用每次运行随机生成的密钥，结果只能在同一次运行中比较
+    HashKey []byte
+}
+
+var (
+    runKeyOnce sync.Once
+    runKey     []byte
+)
+
+// hashKey 获取计算HMAC的密钥
+func (o *Options) hashKey() []byte {
+    if len(o.HashKey) > 0 {
+        return o.HashKey
+    }
+    runKeyOnce.Do(func() {
+        runKey = make([]byte, 32)
+        if _, err := rand.Read(runKey); err != nil {
+            panic("redact: generate hash key failed: " + err.Error())
+        }
+    })
+    return runKey
+}
+
+// patterns 获取实际使用的匹配模式
+func (o *Options) patterns() []string {
+    if o.Patterns == nil {
+        return DefaultPatterns
+    }
+    return o.Patterns
+}
+
+// Match 判断键路径是否是秘密，路径本身或者上级的表匹配时都是秘密
+func (o *Options) Match(keys []string) bool {
+    for i := 1; i <= len(keys); i++ {
+        if o.matchPattern(keys[:i]) {
+            return true
+        }
+    }
+    s := o.Schema
+    for _, name := range keys {
+        if s == nil {
+            return false
+        }
+        s = property(s, name)
+        if s != nil && s.Secret {
+            return true
+        }
+    }
+    return false
+}
+
+func (o *Options) matchPattern(keys []string) bool {
+    p := strings.ToLower(strings.Join(keys, "."))
+    for _, pattern := range o.patterns() {
+        if ok, _ := path.Match(strings.ToLower(pattern), p); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// property 按键名获取下级的约束，数组按其元素的约束查找
+func property(s *schema.Schema, name string) *schema.Schema {
+    for s.Items != nil && len(s.Properties) == 0 && s.AdditionalProperties == nil {
+        s = s.Items
+    }
+    if ps := s.Property(name); ps != nil {
+        return ps
+    }
+    return s.AdditionalProperties
+}
+
+// Value 获取秘密的替代内容
+func (o *Options) Value(obj *xtype.Object) string {
+    if !o.Hash {
+        return Mask
+    }
+    v := util.String(obj.Value)
+    if obj.Type != xtype.TypeString {
+        v = obj.TomlValue()
+    }
+    mac := hmac.New(sha256.New, o.hashKey())
+    mac.Write([]byte(v))
+    return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Redact 隐藏对象中的秘密，返回新的对象，obj不会被修改；秘密替换为字符串，表和数组保留结构，只替换其中的值
+// opts为nil时使用DefaultPatterns
+func Redact(obj *xtype.Object, opts *Options) *xtype.Object {
+    if opts == nil {
+        opts = &Options{}
+    }
+    return opts.redact(nil, opts.Schema, obj, false)
+}
+
+func (o *Options) redact(names []string, s *schema.Schema, obj *xtype.Object, secret bool) *xtype.Object {
+    if !secret && len(names) > 0 {
+        secret = (s != nil && s.Secret) || o.matchPattern(names)
+    }
+    m, ok := obj.Value.(*xtype.Map)
+    if !ok {
+        if !secret {
+            return obj
+        }
+        return &xtype.Object{Value: o.Value(obj), Type: xtype.TypeString, Pos: obj.Pos}
+    }
+    rs := xtype.NewMap()
+    isArray := m.IsArray()
+    if isArray {
+        rs = xtype.NewArrayMap()
+    }
+    for _, k := range m.Keys {
+        child, cs := names, s
+        if isArray {
+            if s != nil {
+                cs = s.Items
+            }
+        } else {
+            child = append(names[:len(names):len(names)], k.Value)
+            if s != nil {
+                cs = s.Property(k.Value)
+                if cs == nil {
+                    cs = s.AdditionalProperties
+                }
+            }
+        }
+        rs.Add(&xtype.Key{Value: k.Value, IsNumeric: k.IsNumeric, Pos: k.Pos}, o.redact(child, cs, m.Data[k], secret))
+    }
+    return &xtype.Object{Value: rs, Type: obj.Type, Pos: obj.Pos}
+}
@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/whencome/toml2x/parser"
+	"github.com/whencome/toml2x/schema"
+	"github.com/whencome/toml2x/xtype"
+)
+
+func TestRedact(t *testing.T) {
+	doc := `name = "app"
+password = "hunter2"
+[db]
+user = "root"
+token = 12345
+[secrets]
+a = "x"
+b = [1, 2]
+[[users]]
+name = "bob"
+api_key = "k1"
+`
+	obj, err := parser.ParseTable(doc)
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	before := obj.Json(false)
+	cases := []struct {
+		opts     *Options
+		expected string
+	}{
+		{nil, `{"name":"app","password":"***","db":{"user":"root","token":"***"},"secrets":{"a":"***","b":["***","***"]},"users":[{"name":"bob","api_key":"***"}]}`},
+		{&Options{Patterns: []string{"DB.*", "users.name"}}, `{"name":"app","password":"hunter2","db":{"user":"***","token":"***"},"secrets":{"a":"x","b":[1,2]},"users":[{"name":"***","api_key":"k1"}]}`},
+		{&Options{Patterns: []string{"password"}, Hash: true, HashKey: []byte("test-key")}, `{"name":"app","password":"hmac:65f93b070e9be4bc","db":{"user":"root","token":12345},"secrets":{"a":"x","b":[1,2]},"users":[{"name":"bob","api_key":"k1"}]}`},
+	}
+	for _, c := range cases {
+		if got := Redact(obj, c.opts).Json(false); got != c.expected {
+			t.Logf("redact with %+v: expected\n%s\ngot\n%s\n", c.opts, c.expected, got)
+			t.Fail()
+		}
+	}
+	if obj.Json(false) != before {
+		t.Logf("redact modified the document\n")
+		t.Fail()
+	}
+
+	// 没有密钥时使用每次运行随机生成的密钥，同一次运行中结果相同
+	random := &Options{Hash: true}
+	secret := xtype.NewStringObject("hunter2")
+	if a, b := random.Value(secret), (&Options{Hash: true}).Value(secret); a != b || !strings.HasPrefix(a, "hmac:") || a == "hmac:65f93b070e9be4bc" {
+		t.Logf("hash with the random key: got %s and %s\n", a, b)
+		t.Fail()
+	}
+
+	s, err := schema.ParseJSON("", `{"properties": {"name": {"secret": true}, "users": {"items": {"properties": {"name": {"secret": true}}}}}}`)
+	if err != nil {
+		t.Fatalf("load schema failed: %s\n", err)
+	}
+	expected := `{"name":"***","password":"hunter2","db":{"user":"root","token":12345},"secrets":{"a":"x","b":[1,2]},"users":[{"name":"***","api_key":"k1"}]}`
+	if got := Redact(obj, &Options{Patterns: []string{}, Schema: s}).Json(false); got != expected {
+		t.Logf("redact with schema: expected\n%s\ngot\n%s\n", expected, got)
+		t.Fail()
+	}
+}
+
+func TestMatch(t *testing.T) {
+	s, _ := schema.ParseJSON("", `{"properties": {"users": {"type": "array", "items": {"properties": {"pin": {"secret": true}}}}}}`)
+	opts := &Options{Patterns: []string{"*password*", "*.token"}, Schema: s}
+	cases := map[string]bool{
+		"password":         true,
+		"db.Password_Hash": true,
+		"api.token":        true,
+		"token":            false,
+		"users.pin":        true,
+		"users.name":       false,
+		"name":             false,
+	}
+	for path, expected := range cases {
+		if got := opts.Match(strings.Split(path, ".")); got != expected {
+			t.Logf("match %s: expected %v, got %v\n", path, expected, got)
+			t.Fail()
+		}
+	}
+}
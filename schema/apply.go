@@ -0,0 +1,132 @@
+package schema
+
+import (
+    "math"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// ValidationError 文档不满足schema
+type ValidationError struct {
+    Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+    msgs := make([]string, 0, len(e.Violations))
+    for _, v := range e.Violations {
+        msgs = append(msgs, v.Error())
+    }
+    return strings.Join(msgs, "\n")
+}
+
+// Apply 使用schema补全默认值并转换类型，返回新的对象，obj不会被修改
+// 表中缺少的键使用属性的default补全，缺少的表中有需要补全的默认值时创建该表；
+// 值的类型不满足type时尝试转换：字符串转换为整数、数字或布尔值，数字和布尔值转换为字符串，
+// 没有小数部分的浮点数转换为整数；无法转换的值保持不变，由Validate报告
+func (s *Schema) Apply(obj *xtype.Object) *xtype.Object {
+    return apply(s, obj)
+}
+
+func apply(s *Schema, obj *xtype.Object) *xtype.Object {
+    if s != nil && len(s.Types) > 0 && !matchType(s.Types, obj) {
+        if v := coerce(s.Types, obj); v != nil {
+            return v
+        }
+    }
+    m, ok := obj.Value.(*xtype.Map)
+    if !ok {
+        return obj
+    }
+    rs := xtype.NewMap()
+    isArray := m.IsArray()
+    if isArray {
+        rs = xtype.NewArrayMap()
+    }
+    for _, k := range m.Keys {
+        rs.Add(&xtype.Key{Value: k.Value, IsNumeric: k.IsNumeric, Pos: k.Pos}, apply(childSchema(s, k.Value, isArray), m.Data[k]))
+    }
+    if s != nil && !isArray {
+        for _, p := range s.Properties {
+            if rs.GetKey(p.Name) != nil {
+                continue
+            }
+            if v := defaultValue(p.Schema); v != nil {
+                rs.Add(xtype.NewStringKey(p.Name), v)
+            }
+        }
+    }
+    return &xtype.Object{Value: rs, Type: obj.Type, Pos: obj.Pos}
+}
+
+// childSchema 获取子元素的约束，没有约束时返回nil
+func childSchema(s *Schema, name string, isArray bool) *Schema {
+    switch {
+    case s == nil:
+        return nil
+    case isArray:
+        return s.Items
+    }
+    if ps := s.Property(name); ps != nil {
+        return ps
+    }
+    return s.AdditionalProperties
+}
+
+// defaultValue 获取缺少的键的默认值，没有默认值时返回nil
+func defaultValue(s *Schema) *xtype.Object {
+    if s.Default != nil {
+        return apply(s, s.Default)
+    }
+    if len(s.Properties) == 0 || (len(s.Types) > 0 && !contains(s.Types, TypeObject)) {
+        return nil
+    }
+    v := apply(s, xtype.NewMapObject(xtype.NewMap()))
+    if len(v.Value.(*xtype.Map).Keys) == 0 {
+        return nil
+    }
+    return v
+}
+
+// coerce 将值转换为允许的类型之一，无法转换时返回nil
+// 字符串inf、nan不会转换为数字，超出int64范围的数字不会转换为整数
+func coerce(types []string, obj *xtype.Object) *xtype.Object {
+    str := util.String(obj.Value)
+    for _, t := range types {
+        var v *xtype.Object
+        switch {
+        case t == TypeInteger && obj.Type == xtype.TypeString:
+            if n := strings.TrimSpace(str); util.IsNumeric(n) && isInteger(n) && util.IsIntInRange(n) {
+                v = xtype.NewNumberObject(util.NormalizeNumber(n))
+            }
+        case t == TypeInteger && obj.Type == xtype.TypeNumber:
+            if f, err := strconv.ParseFloat(util.NormalizeNumber(str), 64); err == nil && f >= math.MinInt64 && f < math.MaxInt64 && f == math.Trunc(f) {
+                v = xtype.NewNumberObject(strconv.FormatInt(int64(f), 10))
+            }
+        case t == TypeNumber && obj.Type == xtype.TypeString:
+            if n := strings.TrimSpace(str); util.IsNumeric(n) && isFinite(n) {
+                v = xtype.NewNumberObject(util.NormalizeNumber(n))
+            }
+        case t == TypeBoolean && obj.Type == xtype.TypeString:
+            if b, err := strconv.ParseBool(strings.TrimSpace(str)); err == nil {
+                v = xtype.NewBoolObject(strconv.FormatBool(b))
+            }
+        case t == TypeString && obj.Type == xtype.TypeNumber:
+            v = xtype.NewStringObject(util.NormalizeNumber(str))
+        case t == TypeString && obj.Type == xtype.TypeBoolean:
+            v = xtype.NewStringObject(str)
+        }
+        if v != nil {
+            v.Pos = obj.Pos
+            return v
+        }
+    }
+    return nil
+}
+
+// isFinite 判断数字不是inf或者nan
+func isFinite(n string) bool {
+    return !strings.HasSuffix(n, "inf") && !strings.HasSuffix(n, "nan")
+}
@@ -0,0 +1,118 @@
+package schema
+
+import (
+    "bytes"
+    "encoding/json"
+    "strconv"
+
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Object 将schema转换为对象，键按json schema中常见的顺序排列，可以再转换为json或者toml
+func (s *Schema) Object() *xtype.Object {
+    if s.Never {
+        return xtype.NewBoolObject("false")
+    }
+    m := xtype.NewMap()
+    add := func(name string, v *xtype.Object) {
+        m.Add(xtype.NewStringKey(name), v)
+    }
+    if s.Title != "" {
+        add("title", xtype.NewStringObject(s.Title))
+    }
+    if s.Description != "" {
+        add("description", xtype.NewStringObject(s.Description))
+    }
+    if len(s.Types) == 1 {
+        add("type", xtype.NewStringObject(s.Types[0]))
+    } else if len(s.Types) > 1 {
+        add("type", stringArray(s.Types))
+    }
+    if s.Format != "" {
+        add("format", xtype.NewStringObject(s.Format))
+    }
+    if len(s.Enum) > 0 {
+        add("enum", array(s.Enum))
+    }
+    if s.Default != nil {
+        add("default", s.Default)
+    }
+    if s.Secret {
+        add("secret", xtype.NewBoolObject("true"))
+    }
+    if len(s.Properties) > 0 {
+        props := xtype.NewMap()
+        for _, p := range s.Properties {
+            props.Add(xtype.NewStringKey(p.Name), p.Schema.Object())
+        }
+        add("properties", xtype.NewMapObject(props))
+    }
+    if len(s.Required) > 0 {
+        add("required", stringArray(s.Required))
+    }
+    if s.AdditionalProperties != nil {
+        add("additionalProperties", s.AdditionalProperties.Object())
+    }
+    if s.Items != nil {
+        add("items", s.Items.Object())
+    }
+    addInt := func(name string, v *int) {
+        if v != nil {
+            add(name, xtype.NewNumberObject(strconv.Itoa(*v)))
+        }
+    }
+    addNumber := func(name string, v *float64) {
+        if v != nil {
+            add(name, xtype.NewNumberObject(formatNumber(*v)))
+        }
+    }
+    addInt("minItems", s.MinItems)
+    addInt("maxItems", s.MaxItems)
+    if s.UniqueItems {
+        add("uniqueItems", xtype.NewBoolObject("true"))
+    }
+    addNumber("minimum", s.Minimum)
+    addNumber("maximum", s.Maximum)
+    addNumber("exclusiveMinimum", s.ExclusiveMinimum)
+    addNumber("exclusiveMaximum", s.ExclusiveMaximum)
+    addInt("minLength", s.MinLength)
+    addInt("maxLength", s.MaxLength)
+    if s.Pattern != "" {
+        add("pattern", xtype.NewStringObject(s.Pattern))
+    }
+    return xtype.NewMapObject(m)
+}
+
+// Json 输出缩进格式的json schema文档，顶层包含$schema
+func (s *Schema) Json() string {
+    obj := s.Object()
+    if m, ok := obj.Value.(*xtype.Map); ok {
+        doc := xtype.NewMap()
+        doc.Add(xtype.NewStringKey("$schema"), xtype.NewStringObject(Draft))
+        for _, k := range m.Keys {
+            doc.Add(k, m.Data[k])
+        }
+        obj = xtype.NewMapObject(doc)
+    }
+    buf := bytes.Buffer{}
+    if err := json.Indent(&buf, []byte(obj.Json(false)), "", "  "); err != nil {
+        return obj.Json(false)
+    }
+    return buf.String()
+}
+
+func array(items []*xtype.Object) *xtype.Object {
+    m := xtype.NewArrayMap()
+    for i, item := range items {
+        m.Add(xtype.NewNumberKey(strconv.Itoa(i)), item)
+    }
+    return xtype.NewMapObject(m)
+}
+
+func stringArray(items []string) *xtype.Object {
+    objs := make([]*xtype.Object, 0, len(items))
+    for _, item := range items {
+        objs = append(objs, xtype.NewStringObject(item))
+    }
+    return array(objs)
+}
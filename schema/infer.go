@@ -0,0 +1,171 @@
+package schema
+
+import (
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// DefaultMaxEnum 推断枚举时允许的最多的不同值的数量
+const DefaultMaxEnum = 10
+
+// InferOptions 推断schema的选项
+type InferOptions struct {
+    Enums   bool // 为true时为字符串和整数生成枚举，枚举值为文档中出现过的值
+    MaxEnum int  // 不同的值超过该数量时不生成枚举，默认为DefaultMaxEnum
+}
+
+// Infer 根据文档推断schema，opts为nil时使用默认选项
+// 表中出现的键都是必须的；表数组的元素合并为一个schema，只有所有元素中都出现的键才是必须的；
+// 整数与浮点数混合时类型为number，本地日期时间不限制格式，空的Map既可以是表也可以是数组
+func Infer(obj *xtype.Object, opts *InferOptions) *Schema {
+    in := &inferrer{max: DefaultMaxEnum}
+    if opts != nil {
+        in.enums = opts.Enums
+        if opts.MaxEnum > 0 {
+            in.max = opts.MaxEnum
+        }
+    }
+    return in.infer(obj)
+}
+
+type inferrer struct {
+    enums bool
+    max   int
+}
+
+func (in *inferrer) infer(obj *xtype.Object) *Schema {
+    s := &Schema{}
+    switch obj.Type {
+    case xtype.TypeString:
+        s.Types = []string{TypeString}
+        in.enum(s, obj)
+    case xtype.TypeDatetime:
+        s.Types = []string{TypeString}
+        for _, f := range []string{"date-time", "date", "time"} {
+            if matchFormat(f, obj) {
+                s.Format = f
+                break
+            }
+        }
+    case xtype.TypeBoolean:
+        s.Types = []string{TypeBoolean}
+    case xtype.TypeNumber:
+        if isInteger(util.String(obj.Value)) {
+            s.Types = []string{TypeInteger}
+            in.enum(s, obj)
+        } else {
+            s.Types = []string{TypeNumber}
+        }
+    case xtype.TypeMap:
+        m := obj.Value.(*xtype.Map)
+        s.Types = typeOf(obj)
+        if m.IsArray() {
+            for _, k := range m.Keys {
+                s.Items = in.merge(s.Items, in.infer(m.Data[k]))
+            }
+            break
+        }
+        for _, k := range m.Keys {
+            s.Properties = append(s.Properties, &Property{Name: k.Value, Schema: in.infer(m.Data[k])})
+            s.Required = append(s.Required, k.Value)
+        }
+    }
+    return s
+}
+
+func (in *inferrer) enum(s *Schema, obj *xtype.Object) {
+    if in.enums {
+        s.Enum = []*xtype.Object{{Value: obj.Value, Type: obj.Type}}
+    }
+}
+
+// merge 合并两个推断出来的schema，结果同时接受两者接受的值，a会被修改
+func (in *inferrer) merge(a *Schema, b *Schema) *Schema {
+    if a == nil {
+        return b
+    }
+    aObject, bObject := hasType(a, TypeObject), hasType(b, TypeObject)
+    a.Types = mergeTypes(a.Types, b.Types)
+    if a.Format != b.Format {
+        a.Format = ""
+    }
+    if a.Enum != nil && b.Enum != nil {
+        for _, e := range b.Enum {
+            if !inEnum(a.Enum, e) {
+                a.Enum = append(a.Enum, e)
+            }
+        }
+    }
+    if b.Enum == nil || len(a.Enum) > in.max {
+        a.Enum = nil
+    }
+    // 必须的键取交集
+    switch {
+    case aObject && bObject:
+        required := make([]string, 0, len(a.Required))
+        for _, name := range a.Required {
+            if contains(b.Required, name) {
+                required = append(required, name)
+            }
+        }
+        a.Required = required
+    case bObject:
+        a.Required = b.Required
+    }
+    for _, p := range b.Properties {
+        if ps := a.Property(p.Name); ps != nil {
+            in.merge(ps, p.Schema)
+            continue
+        }
+        a.Properties = append(a.Properties, p)
+    }
+    if a.Items == nil {
+        a.Items = b.Items
+    } else if b.Items != nil {
+        a.Items = in.merge(a.Items, b.Items)
+    }
+    return a
+}
+
+// mergeTypes 合并类型，integer与number合并为number，空的Map的类型与表或数组合并时以后者为准
+func mergeTypes(a []string, b []string) []string {
+    if isEmptyContainer(a) && (contains(b, TypeObject) || contains(b, TypeArray)) {
+        return b
+    }
+    if isEmptyContainer(b) && (contains(a, TypeObject) || contains(a, TypeArray)) {
+        return a
+    }
+    types := append([]string{}, a...)
+    for _, t := range b {
+        if !contains(types, t) {
+            types = append(types, t)
+        }
+    }
+    if contains(types, TypeNumber) && contains(types, TypeInteger) {
+        rs := make([]string, 0, len(types))
+        for _, t := range types {
+            if t != TypeInteger {
+                rs = append(rs, t)
+            }
+        }
+        types = rs
+    }
+    return types
+}
+
+func isEmptyContainer(types []string) bool {
+    return len(types) == 2 && contains(types, TypeObject) && contains(types, TypeArray)
+}
+
+func hasType(s *Schema, t string) bool {
+    return contains(s.Types, t)
+}
+
+func contains(list []string, v string) bool {
+    for _, item := range list {
+        if item == v {
+            return true
+        }
+    }
+    return false
+}
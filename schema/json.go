@@ -0,0 +1,141 @@
+package schema
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/whencome/toml2x/xtype"
+)
+
+// jsonDecoder 将json解析为xtype对象，保留键的顺序以及位置
+type jsonDecoder struct {
+    file    string
+    content string
+    dec     *json.Decoder
+    // 已经计算过位置的偏移，位置总是向后计算
+    offset int
+    line   int
+    col    int
+}
+
+// decodeJson 解析json内容，null不能转换为toml的值，因此不支持
+func decodeJson(file string, content string) (*xtype.Object, error) {
+    d := &jsonDecoder{file: file, content: content, dec: json.NewDecoder(strings.NewReader(content)), line: 1, col: 1}
+    d.dec.UseNumber()
+    obj, err := d.value()
+    if err != nil {
+        return nil, err
+    }
+    if _, err := d.dec.Token(); err != io.EOF {
+        return nil, &SchemaError{Pos: d.position(), Msg: "unexpected content after the schema"}
+    }
+    return obj, nil
+}
+
+// position 获取下一个记号的位置
+func (d *jsonDecoder) position() xtype.Position {
+    start := int(d.dec.InputOffset())
+    for start < len(d.content) && strings.IndexByte(" \t\r\n:,", d.content[start]) >= 0 {
+        start++
+    }
+    for d.offset < start {
+        r, size := utf8.DecodeRuneInString(d.content[d.offset:])
+        if r == '\n' {
+            d.line++
+            d.col = 1
+        } else {
+            d.col++
+        }
+        d.offset += size
+    }
+    return xtype.Position{File: d.file, Line: d.line, Column: d.col}
+}
+
+func (d *jsonDecoder) value() (*xtype.Object, error) {
+    pos := d.position()
+    tok, err := d.dec.Token()
+    if err != nil {
+        return nil, d.wrap(err, pos)
+    }
+    var obj *xtype.Object
+    switch t := tok.(type) {
+    case json.Delim:
+        var m *xtype.Map
+        if t == '{' {
+            m = xtype.NewMap()
+            err = d.object(m)
+        } else {
+            m = xtype.NewArrayMap()
+            err = d.array(m)
+        }
+        if err != nil {
+            return nil, err
+        }
+        obj = xtype.NewMapObject(m)
+    case string:
+        obj = xtype.NewStringObject(t)
+    case json.Number:
+        obj = xtype.NewNumberObject(string(t))
+    case bool:
+        obj = xtype.NewBoolObject(strconv.FormatBool(t))
+    default:
+        return nil, &SchemaError{Pos: pos, Msg: "null is not supported"}
+    }
+    obj.Pos = pos
+    return obj, nil
+}
+
+func (d *jsonDecoder) object(m *xtype.Map) error {
+    for d.dec.More() {
+        pos := d.position()
+        tok, err := d.dec.Token()
+        if err != nil {
+            return d.wrap(err, pos)
+        }
+        v, err := d.value()
+        if err != nil {
+            return err
+        }
+        name := tok.(string)
+        if k := m.GetKey(name); k != nil {
+            m.Data[k] = v
+            continue
+        }
+        m.Add(&xtype.Key{Value: name, Pos: pos}, v)
+    }
+    _, err := d.dec.Token()
+    return d.wrap(err, d.position())
+}
+
+func (d *jsonDecoder) array(m *xtype.Map) error {
+    for d.dec.More() {
+        v, err := d.value()
+        if err != nil {
+            return err
+        }
+        k := xtype.NewNumberKey(strconv.Itoa(len(m.Keys)))
+        k.Pos = v.Pos
+        m.Add(k, v)
+    }
+    _, err := d.dec.Token()
+    return d.wrap(err, d.position())
+}
+
+// wrap 将json的解析错误转换为带位置的错误
+func (d *jsonDecoder) wrap(err error, pos xtype.Position) error {
+    if err == nil {
+        return nil
+    }
+    var syntaxErr *json.SyntaxError
+    if errors.As(err, &syntaxErr) {
+        return &SchemaError{Pos: pos, Msg: "invalid json: " + syntaxErr.Error()}
+    }
+    if err == io.EOF || err == io.ErrUnexpectedEOF {
+        return &SchemaError{Pos: pos, Msg: "invalid json: unexpected end of input"}
+    }
+    return &SchemaError{Pos: pos, Msg: "invalid json: " + err.Error()}
+}
@@ -0,0 +1,366 @@
+/**
+ * schema validation of parsed toml documents.
+ * a schema is a subset of JSON Schema (draft 2020-12) and can be written in json or in toml,
+ * violations are reported with the position of the offending value in the toml source.
+ */
+package schema
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// define schema types
+const (
+    TypeString  = "string"
+    TypeInteger = "integer"
+    TypeNumber  = "number"
+    TypeBoolean = "boolean"
+    TypeObject  = "object"
+    TypeArray   = "array"
+)
+
+// Draft 生成的schema使用的版本
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema 定义值的约束，为空时接受任意值
+type Schema struct {
+    Title       string
+    Description string
+    Types       []string // 允许的类型，为空时不限制；日期时间视为字符串
+
+    // 表
+    Properties           []*Property // 按定义顺序排列的属性
+    Required             []string    // 必须存在的键
+    AdditionalProperties *Schema     // 未在Properties中定义的键需要满足的约束，为nil时不限制
+
+    // 数组
+    Items       *Schema // 每个元素需要满足的约束
+    MinItems    *int
+    MaxItems    *int
+    UniqueItems bool
+
+    // 数字
+    Minimum          *float64
+    Maximum          *float64
+    ExclusiveMinimum *float64
+    ExclusiveMaximum *float64
+
+    // 字符串
+    MinLength *int   // 按字符计算
+    MaxLength *int   // 按字符计算
+    Pattern   string // 正则表达式，语法见regexp包，不要求完整匹配
+    Format    string // 支持date-time、date、time，其他格式不做检查
+
+    Enum    []*xtype.Object // 允许的值
+    Default *xtype.Object   // 默认值
+    Never   bool            // 为true时不接受任何值，即json schema中的false
+    Secret  bool            // 值是秘密，错误信息中不引用其内容，转换时可以隐藏，扩展关键字secret
+
+    Pos     xtype.Position // schema在其源文件中的位置
+    pattern *regexp.Regexp
+}
+
+// Property 表中的一个属性
+type Property struct {
+    Name   string
+    Schema *Schema
+}
+
+// Property 获取属性的约束，未定义时返回nil
+func (s *Schema) Property(name string) *Schema {
+    for _, p := range s.Properties {
+        if p.Name == name {
+            return p.Schema
+        }
+    }
+    return nil
+}
+
+// unsupported 不支持的关键字，出现时返回错误以免约束被静默忽略
+var unsupported = []string{"$ref", "$dynamicRef", "allOf", "anyOf", "oneOf", "not", "if", "then", "else",
+    "patternProperties", "dependentRequired", "dependentSchemas", "prefixItems", "contains", "unevaluatedProperties", "unevaluatedItems"}
+
+// Parse 解析schema，扩展名为.json或者内容以{开头时按json解析，否则按toml解析
+func Parse(file string, content string) (*Schema, error) {
+    if strings.EqualFold(filepath.Ext(file), ".json") || strings.HasPrefix(strings.TrimSpace(content), "{") {
+        return ParseJSON(file, content)
+    }
+    return ParseTOML(file, content)
+}
+
+// ParseJSON 解析json格式的schema，file用于错误信息以及位置
+func ParseJSON(file string, content string) (*Schema, error) {
+    obj, err := decodeJson(file, content)
+    if err != nil {
+        return nil, err
+    }
+    return New(obj)
+}
+
+// ParseTOML 解析toml格式的schema，关键字与json schema相同，如
+//   type = "object"
+//   required = ["port"]
+//   [properties.port]
+//   type = "integer"
+func ParseTOML(file string, content string) (*Schema, error) {
+    obj, err := parser.ParseWithOptions("table", content, &parser.Options{File: file})
+    if err != nil {
+        return nil, err
+    }
+    return New(obj)
+}
+
+// New 根据解析后的对象创建schema
+func New(obj *xtype.Object) (*Schema, error) {
+    if obj.Type == xtype.TypeBoolean {
+        return &Schema{Never: util.String(obj.Value) == "false", Pos: obj.Pos}, nil
+    }
+    m, ok := obj.Value.(*xtype.Map)
+    if !ok || m.IsArray() {
+        return nil, schemaError(obj, "schema must be an object or a boolean")
+    }
+    s := &Schema{Pos: obj.Pos}
+    for _, k := range m.Keys {
+        v := m.Data[k]
+        var err error
+        switch k.Value {
+        case "type":
+            s.Types, err = stringList(v, true)
+            for _, t := range s.Types {
+                if !isType(t) {
+                    return nil, schemaError(v, "unknown type "+t)
+                }
+            }
+        case "title":
+            s.Title, err = stringValue(v)
+        case "description":
+            s.Description, err = stringValue(v)
+        case "properties":
+            err = s.parseProperties(v)
+        case "required":
+            s.Required, err = stringList(v, false)
+        case "additionalProperties":
+            s.AdditionalProperties, err = New(v)
+        case "items":
+            s.Items, err = New(v)
+        case "minItems":
+            s.MinItems, err = intValue(v)
+        case "maxItems":
+            s.MaxItems, err = intValue(v)
+        case "uniqueItems":
+            s.UniqueItems, err = boolValue(v)
+        case "minimum":
+            s.Minimum, err = numberValue(v)
+        case "maximum":
+            s.Maximum, err = numberValue(v)
+        case "exclusiveMinimum":
+            s.ExclusiveMinimum, err = numberValue(v)
+        case "exclusiveMaximum":
+            s.ExclusiveMaximum, err = numberValue(v)
+        case "minLength":
+            s.MinLength, err = intValue(v)
+        case "maxLength":
+            s.MaxLength, err = intValue(v)
+        case "pattern":
+            if s.Pattern, err = stringValue(v); err == nil {
+                if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+                    err = schemaError(v, "invalid pattern: "+err.Error())
+                }
+            }
+        case "format":
+            s.Format, err = stringValue(v)
+        case "enum":
+            s.Enum, err = list(v)
+        case "const":
+            s.Enum = []*xtype.Object{v}
+        case "default":
+            s.Default = v
+        case "secret":
+            s.Secret, err = boolValue(v)
+        default:
+            for _, kw := range unsupported {
+                if k.Value == kw {
+                    return nil, schemaError(v, "unsupported keyword "+kw)
+                }
+            }
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return s, nil
+}
+
+func (s *Schema) parseProperties(v *xtype.Object) error {
+    m, ok := v.Value.(*xtype.Map)
+    if !ok || (len(m.Keys) > 0 && m.IsArray()) {
+        return schemaError(v, "properties must be an object")
+    }
+    for _, k := range m.Keys {
+        ps, err := New(m.Data[k])
+        if err != nil {
+            return err
+        }
+        s.Properties = append(s.Properties, &Property{Name: k.Value, Schema: ps})
+    }
+    return nil
+}
+
+// SchemaError schema本身的错误
+type SchemaError struct {
+    Pos xtype.Position
+    Msg string
+}
+
+func (e *SchemaError) Error() string {
+    if !e.Pos.IsValid() {
+        return e.Msg
+    }
+    return e.Pos.String() + ": " + e.Msg
+}
+
+func schemaError(v *xtype.Object, msg string) error {
+    return &SchemaError{Pos: v.Pos, Msg: msg}
+}
+
+func isType(t string) bool {
+    switch t {
+    case TypeString, TypeInteger, TypeNumber, TypeBoolean, TypeObject, TypeArray:
+        return true
+    }
+    return false
+}
+
+func stringValue(v *xtype.Object) (string, error) {
+    if v.Type != xtype.TypeString {
+        return "", schemaError(v, "expected a string")
+    }
+    return util.String(v.Value), nil
+}
+
+func boolValue(v *xtype.Object) (bool, error) {
+    if v.Type != xtype.TypeBoolean {
+        return false, schemaError(v, "expected a boolean")
+    }
+    return util.String(v.Value) == "true", nil
+}
+
+func numberValue(v *xtype.Object) (*float64, error) {
+    if v.Type != xtype.TypeNumber {
+        return nil, schemaError(v, "expected a number")
+    }
+    f, err := strconv.ParseFloat(util.NormalizeNumber(util.String(v.Value)), 64)
+    if err != nil {
+        return nil, schemaError(v, "invalid number "+util.String(v.Value))
+    }
+    return &f, nil
+}
+
+func intValue(v *xtype.Object) (*int, error) {
+    n, err := strconv.Atoi(util.NormalizeNumber(util.String(v.Value)))
+    if v.Type != xtype.TypeNumber || err != nil || n < 0 {
+        return nil, schemaError(v, "expected a non-negative integer")
+    }
+    return &n, nil
+}
+
+// list 获取数组元素，空的Map视为空数组
+func list(v *xtype.Object) ([]*xtype.Object, error) {
+    m, ok := v.Value.(*xtype.Map)
+    if !ok || (len(m.Keys) > 0 && !m.IsArray()) {
+        return nil, schemaError(v, "expected an array")
+    }
+    items := make([]*xtype.Object, 0, len(m.Keys))
+    for _, k := range m.Keys {
+        items = append(items, m.Data[k])
+    }
+    return items, nil
+}
+
+// stringList 获取字符串数组，single为true时也可以是单个字符串
+func stringList(v *xtype.Object, single bool) ([]string, error) {
+    if single && v.Type == xtype.TypeString {
+        return []string{util.String(v.Value)}, nil
+    }
+    items, err := list(v)
+    if err != nil {
+        return nil, err
+    }
+    rs := make([]string, 0, len(items))
+    for _, item := range items {
+        s, err := stringValue(item)
+        if err != nil {
+            return nil, err
+        }
+        rs = append(rs, s)
+    }
+    return rs, nil
+}
+
+// typeOf 获取值对应的schema类型，整数同时也是number；空的Map既是object也是array
+func typeOf(v *xtype.Object) []string {
+    switch v.Type {
+    case xtype.TypeString, xtype.TypeDatetime:
+        return []string{TypeString}
+    case xtype.TypeBoolean:
+        return []string{TypeBoolean}
+    case xtype.TypeNumber:
+        if isInteger(util.String(v.Value)) {
+            return []string{TypeInteger, TypeNumber}
+        }
+        return []string{TypeNumber}
+    case xtype.TypeMap:
+        m := v.Value.(*xtype.Map)
+        if len(m.Keys) == 0 {
+            return []string{TypeObject, TypeArray}
+        }
+        if m.IsArray() {
+            return []string{TypeArray}
+        }
+        return []string{TypeObject}
+    }
+    return nil
+}
+
+// isInteger 判断数字是否是整数
+func isInteger(v string) bool {
+    v = util.NormalizeNumber(v)
+    return !strings.ContainsAny(v, ".eE") && !strings.HasSuffix(v, "inf") && !strings.HasSuffix(v, "nan")
+}
+
+// typeName 值的类型名称，用于错误信息
+func typeName(v *xtype.Object) string {
+    if v.Type == xtype.TypeDatetime {
+        return "datetime"
+    }
+    return typeOf(v)[0]
+}
+
+// describe 值的描述，用于错误信息
+func describe(v *xtype.Object) string {
+    s := v.TomlValue()
+    // 按字符截断，避免截断多字节字符
+    if chars := []rune(s); len(chars) > 40 {
+        s = string(chars[:37]) + "..."
+    }
+    return s
+}
+
+func formatNumber(f float64) string {
+    return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func plural(n int, word string) string {
+    if n == 1 {
+        return fmt.Sprintf("%d %s", n, word)
+    }
+    return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,395 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/whencome/toml2x/parser"
+)
+
+const jsonSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["name", "server"],
+  "additionalProperties": false,
+  "properties": {
+    "name": { "type": "string", "minLength": 2, "pattern": "^[a-z]+$" },
+    "mode": { "enum": ["dev", "prod"] },
+    "server": {
+      "type": "object",
+      "required": ["port"],
+      "properties": {
+        "port": { "type": "integer", "minimum": 1, "maximum": 65535 },
+        "ratio": { "type": "number", "exclusiveMaximum": 1 },
+        "started": { "type": "string", "format": "date-time" }
+      }
+    },
+    "tags": { "type": "array", "minItems": 1, "maxItems": 2, "uniqueItems": true, "items": { "type": "string" } },
+    "users": { "type": "array", "items": { "type": "object", "required": ["id"] } }
+  }
+}`
+
+const tomlSchema = `
+"$schema" = "https://json-schema.org/draft/2020-12/schema"
+type = "object"
+required = ["name", "server"]
+additionalProperties = false
+
+[properties.name]
+type = "string"
+minLength = 2
+pattern = "^[a-z]+$"
+
+[properties.mode]
+enum = ["dev", "prod"]
+
+[properties.server]
+type = "object"
+required = ["port"]
+properties.port = { type = "integer", minimum = 1, maximum = 65535 }
+properties.ratio = { type = "number", exclusiveMaximum = 1 }
+properties.started = { type = "string", format = "date-time" }
+
+[properties.tags]
+type = "array"
+minItems = 1
+maxItems = 2
+uniqueItems = true
+items = { type = "string" }
+
+[properties.users]
+type = "array"
+items = { type = "object", required = ["id"] }
+`
+
+func TestValidate(t *testing.T) {
+	doc := `name = "A"
+mode = "test"
+extra = 1
+tags = ["a", "b", "a"]
+
+[server]
+ratio = 1.0
+started = 1979-05-27T07:32:00
+
+[[users]]
+name = "x"
+`
+	expected := []string{
+		"app.toml:1:8: name: string is shorter than 2 characters",
+		"app.toml:1:8: name: string does not match pattern ^[a-z]+$",
+		"app.toml:2:8: mode: value \"test\" is not one of \"dev\", \"prod\"",
+		"app.toml:3:1: extra: key extra is not allowed",
+		"app.toml:4:8: tags: array has 3 items, more than 2",
+		"app.toml:4:19: tags[2]: duplicate of item 0",
+		"app.toml:6:1: server.port: required key is missing",
+		"app.toml:7:9: server.ratio: value 1.0 is not less than 1",
+		"app.toml:8:11: server.started: value 1979-05-27T07:32:00 is not a valid date-time",
+		"app.toml:10:1: users[0].id: required key is missing",
+	}
+	obj, err := parser.ParseWithOptions("table", doc, &parser.Options{File: "app.toml"})
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	for name, load := range map[string]func() (*Schema, error){
+		"json": func() (*Schema, error) { return ParseJSON("schema.json", jsonSchema) },
+		"toml": func() (*Schema, error) { return ParseTOML("schema.toml", tomlSchema) },
+	} {
+		s, err := load()
+		if err != nil {
+			t.Fatalf("%s: load schema failed: %s\n", name, err)
+		}
+		violations := s.Validate(obj)
+		got := make([]string, 0, len(violations))
+		for _, v := range violations {
+			got = append(got, v.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Logf("%s: expected\n%s\ngot\n%s\n", name, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+			t.Fail()
+		}
+	}
+
+	valid := "name = \"app\"\nmode = \"prod\"\ntags = [\"a\"]\n[server]\nport = 8080\nratio = 0.5\nstarted = 1979-05-27T07:32:00Z\n[[users]]\nid = 1\n"
+	obj, err = parser.ParseTable(valid)
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	s, _ := ParseJSON("schema.json", jsonSchema)
+	if violations := s.Validate(obj); len(violations) != 0 {
+		t.Logf("expected no violations, got %v\n", violations)
+		t.Fail()
+	}
+}
+
+func TestTypes(t *testing.T) {
+	cases := []struct {
+		schema string
+		value  string
+		valid  bool
+	}{
+		{`{"type": "integer"}`, "1_000", true},
+		{`{"type": "integer"}`, "1.5", false},
+		{`{"type": "number"}`, "1", true},
+		{`{"type": ["string", "boolean"]}`, "true", true},
+		{`{"type": "string"}`, "1979-05-27", true},
+		{`{"type": "array"}`, "[]", true},
+		{`{"type": "object"}`, "[1]", false},
+		{`{"enum": [1, "a"]}`, "1.0", true},
+		{`{"const": "a"}`, "'b'", false},
+		{`{"format": "date"}`, "1979-05-27", true},
+		{`{"format": "time"}`, "07:32:00", true},
+		{`{"format": "date"}`, "'yesterday'", false},
+		{`false`, "1", false},
+		{`true`, "1", true},
+		{`{"items": {"maximum": 3}}`, "[1, 2, 4]", false},
+		{`{"additionalProperties": {"type": "integer"}}`, "{ a = 1, b = 'x' }", false},
+	}
+	for _, c := range cases {
+		s, err := ParseJSON("", c.schema)
+		if err != nil {
+			t.Fatalf("load %s failed: %s\n", c.schema, err)
+		}
+		obj, err := parser.ParseSingle(c.value)
+		if err != nil {
+			t.Fatalf("parse %s failed: %s\n", c.value, err)
+		}
+		if violations := s.Validate(obj); (len(violations) == 0) != c.valid {
+			t.Logf("%s against %s: expected valid=%v, got %v\n", c.value, c.schema, c.valid, violations)
+			t.Fail()
+		}
+	}
+
+	// 过长的值按字符截断
+	s, _ := ParseJSON("", `{"const": "a"}`)
+	obj, _ := parser.ParseSingle(`"` + strings.Repeat("配置", 30) + `"`)
+	expected := `value "` + strings.Repeat("配置", 18) + `... is not one of "a"`
+	if violations := s.Validate(obj); len(violations) != 1 || violations[0].Message != expected {
+		t.Logf("expected %s, got %v\n", expected, violations)
+		t.Fail()
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	cases := map[string]string{
+		"{\n  \"type\": \"float\"\n}":     "s.json:2:11: unknown type float",
+		"{\"minimum\": \"1\"}":            "s.json:1:13: expected a number",
+		"{\"anyOf\": []}":                 "s.json:1:11: unsupported keyword anyOf",
+		"{\"pattern\": \"(\"}":            "s.json:1:13: invalid pattern: error parsing regexp: missing closing ): `(`",
+		"{\"properties\": {\"a\": null}}": "s.json:1:22: null is not supported",
+		"{\"type\": \"string\"":           "s.json:1:18: invalid json: unexpected end of JSON input",
+		"[1]":                             "s.json:1:1: schema must be an object or a boolean",
+		"{\"required\": \"a\"} {}":        "s.json:1:20: unexpected content after the schema",
+	}
+	for content, msg := range cases {
+		_, err := ParseJSON("s.json", content)
+		if err == nil || err.Error() != msg {
+			t.Logf("load %q: expected %s, got %v\n", content, msg, err)
+			t.Fail()
+		}
+	}
+	if _, err := ParseTOML("s.toml", "type = 'object'\nmaxItems = -1\n"); err == nil || err.Error() != "s.toml:2:12: expected a non-negative integer" {
+		t.Logf("expected toml schema error with position, got %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestInfer(t *testing.T) {
+	doc := `name = "app"
+ratio = 1
+empty = []
+started = 1979-05-27T07:32:00Z
+
+[[users]]
+id = 1
+role = "admin"
+tags = ["a"]
+
+[[users]]
+id = 2
+role = "dev"
+score = 0.5
+`
+	expected := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "name": {
+      "type": "string",
+      "enum": [
+        "app"
+      ]
+    },
+    "ratio": {
+      "type": "integer",
+      "enum": [
+        1
+      ]
+    },
+    "empty": {
+      "type": [
+        "object",
+        "array"
+      ]
+    },
+    "started": {
+      "type": "string",
+      "format": "date-time"
+    },
+    "users": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "integer",
+            "enum": [
+              1,
+              2
+            ]
+          },
+          "role": {
+            "type": "string",
+            "enum": [
+              "admin",
+              "dev"
+            ]
+          },
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "string",
+              "enum": [
+                "a"
+              ]
+            }
+          },
+          "score": {
+            "type": "number"
+          }
+        },
+        "required": [
+          "id",
+          "role"
+        ]
+      }
+    }
+  },
+  "required": [
+    "name",
+    "ratio",
+    "empty",
+    "started",
+    "users"
+  ]
+}`
+	obj, err := parser.ParseTable(doc)
+	if err != nil {
+		t.Fatalf("parse failed: %s\n", err)
+	}
+	s := Infer(obj, &InferOptions{Enums: true})
+	if got := s.Json(); got != expected {
+		t.Logf("expected\n%s\ngot\n%s\n", expected, got)
+		t.Fail()
+	}
+	// 推断的schema总是接受原文档，并且可以重新解析
+	for _, opts := range []*InferOptions{nil, {Enums: true, MaxEnum: 1}} {
+		s := Infer(obj, opts)
+		if violations := s.Validate(obj); len(violations) != 0 {
+			t.Logf("inferred schema rejects its document: %v\n", violations)
+			t.Fail()
+		}
+		parsed, err := ParseJSON("", s.Json())
+		if err != nil || parsed.Json() != s.Json() {
+			t.Logf("reparse inferred schema: %v\n%s\n", err, s.Json())
+			t.Fail()
+		}
+	}
+	if s := Infer(obj, &InferOptions{Enums: true, MaxEnum: 1}); s.Property("users").Items.Property("id").Enum != nil {
+		t.Logf("expected no enum with more values than MaxEnum\n")
+		t.Fail()
+	}
+}
+
+func TestApply(t *testing.T) {
+	s, err := ParseTOML("schema.toml", `
+type = "object"
+[properties.port]
+type = "integer"
+default = 8080
+[properties.name]
+type = "string"
+[properties.debug]
+type = "boolean"
+[properties.ratio]
+type = "number"
+[properties.db]
+type = "object"
+properties.host = { type = "string", default = "localhost" }
+properties.pool = { type = "integer" }
+[properties.ids]
+type = "array"
+items = { type = "integer" }
+`)
+	if err != nil {
+		t.Fatalf("load schema failed: %s\n", err)
+	}
+	cases := map[string]string{
+		``:                                 `{"port":8080,"db":{"host":"localhost"}}`,
+		`port = "9090"`:                    `{"port":9090,"db":{"host":"localhost"}}`,
+		`port = 9090.0`:                    `{"port":9090,"db":{"host":"localhost"}}`,
+		`name = 1_000`:                     `{"name":"1000","port":8080,"db":{"host":"localhost"}}`,
+		`name = true`:                      `{"name":"true","port":8080,"db":{"host":"localhost"}}`,
+		`debug = "false"`:                  `{"debug":false,"port":8080,"db":{"host":"localhost"}}`,
+		`ratio = " 0.5 "`:                  `{"ratio":0.5,"port":8080,"db":{"host":"localhost"}}`,
+		"[db]\npool = '10'":                `{"db":{"pool":10,"host":"localhost"},"port":8080}`,
+		"ids = ['1', 2, '0x10']":           `{"ids":[1,2,16],"port":8080,"db":{"host":"localhost"}}`,
+		"port = 'http'\nratio = 'inf'\n":   `{"port":"http","ratio":"inf","db":{"host":"localhost"}}`,
+		"ratio = 'nan'":                    `{"ratio":"nan","port":8080,"db":{"host":"localhost"}}`,
+		"port = 1e19":                      `{"port":1e19,"db":{"host":"localhost"}}`,
+		"port = -1e300":                    `{"port":-1e300,"db":{"host":"localhost"}}`,
+		"port = '99999999999999999999'":    `{"port":"99999999999999999999","db":{"host":"localhost"}}`,
+		"port = 80.5\nextra = '1'\n[db]\n": `{"port":80.5,"extra":"1","db":{"host":"localhost"}}`,
+	}
+	for doc, expected := range cases {
+		obj, err := parser.ParseTable(doc)
+		if err != nil {
+			t.Fatalf("parse %q failed: %s\n", doc, err)
+		}
+		before := obj.Json(false)
+		if got := s.Apply(obj).Json(false); got != expected {
+			t.Logf("apply %q: expected %s, got %s\n", doc, expected, got)
+			t.Fail()
+		}
+		if obj.Json(false) != before {
+			t.Logf("apply %q modified the document\n", doc)
+			t.Fail()
+		}
+	}
+
+	obj, _ := parser.ParseWithOptions("table", "port = 'http'\n", &parser.Options{File: "app.toml"})
+	violations := s.Validate(s.Apply(obj))
+	if len(violations) != 1 || violations[0].Error() != "app.toml:1:8: port: expected integer, got string" {
+		t.Logf("expected the value that can not be coerced to keep its position, got %v\n", violations)
+		t.Fail()
+	}
+}
+
+func TestValidateRedacted(t *testing.T) {
+	s, err := ParseJSON("", `{"properties": {"pin": {"enum": [1234, 5678], "secret": true}, "db": {"properties": {"password": {"minLength": 1, "format": "date"}}}}}`)
+	if err != nil {
+		t.Fatalf("load schema failed: %s\n", err)
+	}
+	obj, _ := parser.ParseWithOptions("table", "pin = 1\n[db]\npassword = 'hunter2'\n", &parser.Options{File: "app.toml"})
+	secret := func(keys []string) bool { return strings.HasSuffix(strings.Join(keys, "."), "password") }
+	expected := "app.toml:1:7: pin: value *** is not one of the allowed values\n" +
+		"app.toml:3:12: db.password: value *** is not a valid date"
+	got := make([]string, 0)
+	for _, v := range s.ValidateRedacted(obj, secret) {
+		got = append(got, v.Error())
+	}
+	if strings.Join(got, "\n") != expected {
+		t.Logf("expected\n%s\ngot\n%s\n", expected, strings.Join(got, "\n"))
+		t.Fail()
+	}
+}
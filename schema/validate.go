@@ -0,0 +1,262 @@
+package schema
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/whencome/toml2x/diff"
+    "github.com/whencome/toml2x/util"
+    "github.com/whencome/toml2x/xtype"
+)
+
+// Violation 不满足schema的值
+type Violation struct {
+    Path    string         // 值的路径，格式见diff.FormatPath，为空时表示整个文档
+    Pos     xtype.Position // 值在toml源文件中的位置，缺少的键使用其所在表的位置
+    Message string
+}
+
+// Error 以 file:line:col: path: message 的形式输出
+func (v Violation) Error() string {
+    msg := v.Message
+    if v.Path != "" {
+        msg = v.Path + ": " + msg
+    }
+    if pos := v.Pos.String(); pos != "" {
+        return pos + ": " + msg
+    }
+    return msg
+}
+
+// Validate 校验对象，返回所有不满足约束的值，按文档中的顺序排列
+func (s *Schema) Validate(obj *xtype.Object) []Violation {
+    return s.ValidateRedacted(obj, nil)
+}
+
+// ValidateRedacted 与Validate相同，secret判断键路径是否是秘密（路径由键名组成，不包含数组下标），
+// 秘密以及标记了secret的值在信息中显示为***
+func (s *Schema) ValidateRedacted(obj *xtype.Object, secret func(keys []string) bool) []Violation {
+    v := &validator{violations: make([]Violation, 0), secret: secret}
+    v.validate(s, nil, nil, obj)
+    return v.violations
+}
+
+type validator struct {
+    violations []Violation
+    secret     func(keys []string) bool
+    // 当前的值是否是秘密
+    masked bool
+}
+
+// describe 值的描述，秘密显示为***
+func (v *validator) describe(obj *xtype.Object) string {
+    if v.masked {
+        return "***"
+    }
+    return describe(obj)
+}
+
+// enumValues 枚举值的描述，秘密的枚举值也不显示
+func (v *validator) enumValues(enum []*xtype.Object) string {
+    if v.masked {
+        return "the allowed values"
+    }
+    values := make([]string, 0, len(enum))
+    for _, e := range enum {
+        values = append(values, describe(e))
+    }
+    return strings.Join(values, ", ")
+}
+
+func (v *validator) report(keys []string, pos xtype.Position, msg string) {
+    v.violations = append(v.violations, Violation{Path: diff.FormatPath(keys), Pos: pos, Message: msg})
+}
+
+func (v *validator) validate(s *Schema, keys []string, names []string, obj *xtype.Object) {
+    masked := v.masked
+    defer func() { v.masked = masked }()
+    v.masked = masked || s.Secret || (v.secret != nil && len(names) > 0 && v.secret(names))
+    if s.Never {
+        v.report(keys, obj.Pos, "value is not allowed")
+        return
+    }
+    if len(s.Types) > 0 && !matchType(s.Types, obj) {
+        v.report(keys, obj.Pos, "expected "+strings.Join(s.Types, " or ")+", got "+typeName(obj))
+        return
+    }
+    if len(s.Enum) > 0 && !inEnum(s.Enum, obj) {
+        v.report(keys, obj.Pos, "value "+v.describe(obj)+" is not one of "+v.enumValues(s.Enum))
+    }
+    switch obj.Type {
+    case xtype.TypeNumber:
+        v.validateNumber(s, keys, obj)
+    case xtype.TypeString, xtype.TypeDatetime:
+        v.validateString(s, keys, obj)
+    case xtype.TypeMap:
+        m := obj.Value.(*xtype.Map)
+        if len(m.Keys) == 0 || !m.IsArray() {
+            v.validateTable(s, keys, names, obj, m)
+        }
+        if len(m.Keys) == 0 || m.IsArray() {
+            v.validateArray(s, keys, names, obj, m)
+        }
+    }
+}
+
+func (v *validator) validateNumber(s *Schema, keys []string, obj *xtype.Object) {
+    n, err := strconv.ParseFloat(util.NormalizeNumber(util.String(obj.Value)), 64)
+    if err != nil {
+        return
+    }
+    val := util.String(obj.Value)
+    if v.masked {
+        val = "***"
+    }
+    switch {
+    case s.Minimum != nil && n < *s.Minimum:
+        v.report(keys, obj.Pos, "value "+val+" is less than minimum "+formatNumber(*s.Minimum))
+    case s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum:
+        v.report(keys, obj.Pos, "value "+val+" is not greater than "+formatNumber(*s.ExclusiveMinimum))
+    }
+    switch {
+    case s.Maximum != nil && n > *s.Maximum:
+        v.report(keys, obj.Pos, "value "+val+" is greater than maximum "+formatNumber(*s.Maximum))
+    case s.ExclusiveMaximum != nil && n >= *s.ExclusiveMaximum:
+        v.report(keys, obj.Pos, "value "+val+" is not less than "+formatNumber(*s.ExclusiveMaximum))
+    }
+}
+
+func (v *validator) validateString(s *Schema, keys []string, obj *xtype.Object) {
+    str := util.String(obj.Value)
+    size := utf8.RuneCountInString(str)
+    if s.MinLength != nil && size < *s.MinLength {
+        v.report(keys, obj.Pos, "string is shorter than "+plural(*s.MinLength, "character"))
+    }
+    if s.MaxLength != nil && size > *s.MaxLength {
+        v.report(keys, obj.Pos, "string is longer than "+plural(*s.MaxLength, "character"))
+    }
+    if s.Pattern != "" {
+        re := s.pattern
+        if re == nil {
+            re, _ = regexp.Compile(s.Pattern)
+        }
+        if re != nil && !re.MatchString(str) {
+            v.report(keys, obj.Pos, "string does not match pattern "+s.Pattern)
+        }
+    }
+    if s.Format != "" && !matchFormat(s.Format, obj) {
+        v.report(keys, obj.Pos, "value "+v.describe(obj)+" is not a valid "+s.Format)
+    }
+}
+
+func (v *validator) validateTable(s *Schema, keys []string, names []string, obj *xtype.Object, m *xtype.Map) {
+    for _, name := range s.Required {
+        if m.GetKey(name) == nil {
+            v.report(append(keys, name), obj.Pos, "required key is missing")
+        }
+    }
+    for _, k := range m.Keys {
+        path := append(keys, k.Value)
+        child := append(names[:len(names):len(names)], k.Value)
+        if ps := s.Property(k.Value); ps != nil {
+            v.validate(ps, path, child, m.Data[k])
+            continue
+        }
+        if s.AdditionalProperties == nil {
+            continue
+        }
+        if s.AdditionalProperties.Never {
+            v.report(path, k.Pos, "key "+k.Value+" is not allowed")
+            continue
+        }
+        v.validate(s.AdditionalProperties, path, child, m.Data[k])
+    }
+}
+
+func (v *validator) validateArray(s *Schema, keys []string, names []string, obj *xtype.Object, m *xtype.Map) {
+    size := len(m.Keys)
+    if s.MinItems != nil && size < *s.MinItems {
+        v.report(keys, obj.Pos, "array has "+plural(size, "item")+", fewer than "+strconv.Itoa(*s.MinItems))
+    }
+    if s.MaxItems != nil && size > *s.MaxItems {
+        v.report(keys, obj.Pos, "array has "+plural(size, "item")+", more than "+strconv.Itoa(*s.MaxItems))
+    }
+    for i, k := range m.Keys {
+        item := m.Data[k]
+        if s.UniqueItems {
+            for j := 0; j < i; j++ {
+                if valueEqual(m.Data[m.Keys[j]], item) {
+                    v.report(append(keys, k.Value), item.Pos, "duplicate of item "+strconv.Itoa(j))
+                    break
+                }
+            }
+        }
+        if s.Items != nil {
+            v.validate(s.Items, append(keys, k.Value), names, item)
+        }
+    }
+}
+
+// matchType 判断值是否满足类型约束
+func matchType(types []string, obj *xtype.Object) bool {
+    for _, t := range types {
+        for _, vt := range typeOf(obj) {
+            if t == vt {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// inEnum 判断值是否是枚举值之一
+func inEnum(enum []*xtype.Object, obj *xtype.Object) bool {
+    for _, e := range enum {
+        if valueEqual(e, obj) {
+            return true
+        }
+    }
+    return false
+}
+
+// valueEqual 按json schema的规则比较两个值，1与1.0相等，日期时间与相同内容的字符串相等
+func valueEqual(a *xtype.Object, b *xtype.Object) bool {
+    if a.Type == xtype.TypeNumber && b.Type == xtype.TypeNumber {
+        fa, errA := strconv.ParseFloat(util.NormalizeNumber(util.String(a.Value)), 64)
+        fb, errB := strconv.ParseFloat(util.NormalizeNumber(util.String(b.Value)), 64)
+        return errA == nil && errB == nil && fa == fb
+    }
+    if isString(a) && isString(b) {
+        return util.String(a.Value) == util.String(b.Value)
+    }
+    return diff.Equal(a, b)
+}
+
+func isString(o *xtype.Object) bool {
+    return o.Type == xtype.TypeString || o.Type == xtype.TypeDatetime
+}
+
+// matchFormat 检查字符串格式，日期时间类型的值需要与格式一致
+func matchFormat(format string, obj *xtype.Object) bool {
+    str := util.String(obj.Value)
+    switch format {
+    case "date-time":
+        return util.IsDatetime(str) && strings.ContainsAny(str, "Tt ") && strings.Contains(str, ":") && hasOffset(str)
+    case "date":
+        return util.IsDatetime(str) && len(str) == 10
+    case "time":
+        return util.IsDatetime(str) && len(str) >= 8 && str[2] == ':'
+    }
+    return true
+}
+
+// hasOffset 判断日期时间是否包含时区
+func hasOffset(s string) bool {
+    if strings.HasSuffix(s, "Z") || strings.HasSuffix(s, "z") {
+        return true
+    }
+    i := strings.LastIndexAny(s, "+-")
+    return i > 10 && strings.Count(s[i:], ":") == 1
+}
@@ -2,18 +2,81 @@ package toml2x
 
 import (
     "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/crypt"
     "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/redact"
+    "github.com/whencome/toml2x/schema"
     "github.com/whencome/toml2x/xtype"
 )
 
+// Options 转换选项
+type Options struct {
+    File   string            // 配置文件名，用于错误信息以及键和值的位置
+    Limits *formatter.Limits // 资源限制，处理不可信的输入时使用，超出限制时返回*formatter.LimitError
+    // 变量展开选项，为nil时不展开，见parser.Interpolation
+    Interpolation *parser.Interpolation
+    // 包含指令选项，为nil时不处理包含指令，见parser.Include
+    Include *parser.Include
+    // 转换时使用的配置方案，为空时配置方案按普通的表输出，见ApplyProfile
+    Profile     string
+    ProfileKeys []string // 存放配置方案的表，为空时使用DefaultProfileKeys
+    // 校验时使用的schema，为nil时只检查语法；
+    // 转换时先用schema补全默认值并转换类型（见schema.Schema.Apply），结果不满足schema时返回*schema.ValidationError
+    Schema *schema.Schema
+    // 输出格式为schema时推断schema的选项，为nil时使用默认选项
+    Infer *schema.InferOptions
+    // 隐藏秘密的选项，为nil时不隐藏；秘密在所有输出格式以及错误信息中显示为掩码，
+    // Redact.Schema为nil时使用Schema中标记的secret
+    Redact *redact.Options
+    // 解密 ENC[AES256_GCM,...] 形式的值使用的密钥，为nil时加密的值保持原样，见crypt包
+    Keys crypt.KeyProvider
+}
+
+// parserOptions 转换为解析选项
+func (opts *Options) parserOptions() *parser.Options {
+    po := &parser.Options{File: opts.File, Limits: opts.Limits, Interpolation: opts.Interpolation, Include: opts.Include, Secret: opts.secret()}
+    if opts.Keys != nil {
+        po.Decrypt = crypt.Decrypter(opts.Keys)
+    }
+    return po
+}
+
+// redactOptions 获取隐藏秘密的选项，未设置schema时使用校验的schema
+func (opts *Options) redactOptions() *redact.Options {
+    if opts.Redact == nil || opts.Redact.Schema != nil || opts.Schema == nil {
+        return opts.Redact
+    }
+    r := *opts.Redact
+    r.Schema = opts.Schema
+    return &r
+}
+
+// secret 判断键路径是否是秘密的函数，不隐藏秘密时为nil
+func (opts *Options) secret() func(keys []string) bool {
+    if r := opts.redactOptions(); r != nil {
+        return r.Match
+    }
+    return nil
+}
+
 // parse 解析toml配置内容
 // toml toml格式的配置内容
 func parse(dataType string, toml string) (*xtype.Object, error) {
-    toml, err := formatter.Normalize(toml)
-    if err != nil {
-        return nil, err
+    return parseWithOptions(dataType, toml, nil)
+}
+
+// Parse 解析toml配置内容，返回解析后的对象
+// dataType 配置的数据类型，single，table
+func Parse(dataType string, toml string, opts *Options) (*xtype.Object, error) {
+    return parseWithOptions(dataType, toml, opts)
+}
+
+// parseWithOptions 使用指定的选项解析toml配置内容
+func parseWithOptions(dataType string, toml string, opts *Options) (*xtype.Object, error) {
+    if opts == nil {
+        opts = &Options{}
     }
-    obj, err := parser.Parse(dataType, toml)
+    obj, err := parser.ParseWithOptions(dataType, toml, opts.parserOptions())
     if err != nil {
         return nil, err
     }
@@ -52,3 +115,26 @@ func Php(dataType string, toml string) (string, error) {
     }
     return obj.Php(), nil
 }
+
+// Toml 转换为规范化的toml格式
+// dataType 配置的数据类型，single，table
+// toml toml配置内容
+// sorted 是否按键名排序输出
+func Toml(dataType string, toml string, sorted bool) (string, error) {
+    obj, err := parse(dataType, toml)
+    if err != nil {
+        return "", err
+    }
+    return obj.Toml(sorted), nil
+}
+
+// Yaml 转换为yaml格式
+// dataType 配置的数据类型，single，table
+// toml toml配置内容
+func Yaml(dataType string, toml string) (string, error) {
+    obj, err := parse(dataType, toml)
+    if err != nil {
+        return "", err
+    }
+    return obj.Yaml(), nil
+}
@@ -3,10 +3,12 @@ package toml2x
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/whencome/toml2x/formatter"
 	"github.com/whencome/toml2x/parser"
+	"github.com/whencome/toml2x/xtype"
 )
 
 func TestParseSingle(t *testing.T) {
@@ -151,3 +153,245 @@ func TestParseTable(t *testing.T) {
 
 	t.Logf("%+v\n", rs)
 }
+
+func TestToml(t *testing.T) {
+	tomlTable := `title = "TOML Example"
+port = +8_080
+mask = 0xDEAD_BEEF
+mode = 0o755
+exp = 6.626E-34
+empty = []
+[owner]
+name = "Tom"
+dob = 1979-05-27 07:32:00z
+[[servers]]
+ip = "10.0.0.1"
+roles = [ "web", "api" ]
+[[servers]]
+ip = "10.0.0.2"`
+	expected := `title = "TOML Example"
+port  = 8080
+mask  = 0xDEADBEEF
+mode  = 0o755
+exp   = 6.626e-34
+empty = []
+
+[owner]
+name = "Tom"
+dob  = 1979-05-27T07:32:00Z
+
+[[servers]]
+ip    = "10.0.0.1"
+roles = ["web", "api"]
+
+[[servers]]
+ip = "10.0.0.2"
+`
+	rs, err := Toml("table", tomlTable, false)
+	if err != nil {
+		t.Logf("TestToml failed: %s \n", err)
+		t.Fail()
+		return
+	}
+	if rs != expected {
+		t.Logf("unexpected toml output:\n%s\n", rs)
+		t.Fail()
+	}
+
+	// 格式化后的内容再次格式化应保持不变
+	again, err := Toml("table", rs, false)
+	if err != nil || again != rs {
+		t.Logf("toml output is not stable:\n%s\n", again)
+		t.Fail()
+	}
+
+	sortedExpected := `empty = []
+exp   = 6.626e-34
+mask  = 0xDEADBEEF
+mode  = 0o755
+port  = 8080
+title = "TOML Example"
+
+[owner]
+dob  = 1979-05-27T07:32:00Z
+name = "Tom"
+
+[[servers]]
+ip    = "10.0.0.1"
+roles = ["web", "api"]
+
+[[servers]]
+ip = "10.0.0.2"
+`
+	sorted, err := Toml("table", tomlTable, true)
+	if err != nil || sorted != sortedExpected {
+		t.Logf("unexpected sorted toml output: %v\n%s\n", err, sorted)
+		t.Fail()
+	}
+}
+
+func TestValidate(t *testing.T) {
+	toml := "a = 1\nb = \"unterminated\nc = 2\n[c]\nd = tru\n"
+	issues := Validate(toml, &Options{File: "app.toml"})
+	expected := []string{
+		"app.toml:2:5: new lines not allowed on single line strings",
+		"app.toml:4:2: key c is already defined as a number",
+		"app.toml:5:5: invalid value tru",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v\n", len(expected), issues)
+	}
+	for i, issue := range issues {
+		if issue.String() != expected[i] {
+			t.Logf("issue %d: expected %s, got %s\n", i, expected[i], issue)
+			t.Fail()
+		}
+	}
+	if issues := Validate("a = 1\n", nil); len(issues) != 0 {
+		t.Logf("expected no issues, got %v\n", issues)
+		t.Fail()
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	toml := "# comment\n[a]\nb = 1 # one\nc = [1, 2]\n"
+	obj, err := Get(toml, "a.b", nil)
+	if err != nil || obj.Json(false) != "1" {
+		t.Logf("get a.b: expected 1, got %v %v\n", obj, err)
+		t.Fail()
+	}
+	if _, err := Get(toml, "a.x", nil); err == nil {
+		t.Logf("get a.x: expected error\n")
+		t.Fail()
+	}
+	rs, err := Set(toml, "a.b", xtype.NewStringObject("x\"y"), nil)
+	if err != nil || rs != "# comment\n[a]\nb = \"x\\\"y\" # one\nc = [1, 2]\n" {
+		t.Logf("set a.b: unexpected result %q %v\n", rs, err)
+		t.Fail()
+	}
+
+	// 表数组中的元素通过下标原样修改，行内表以及数组整体替换，新增的键不影响注释
+	servers := "# servers\nname = \"demo\" # name\n\n[[s]]\nip = \"a\" # first\n\n[[s]]\nip = \"b\"\n\n[t]\nc = [1, 2] # ports\nd = { x = 1 } # inline\n"
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"s[0].ip", strings.Replace(servers, "ip = \"a\"", "ip = \"x\"", 1)},
+		{"s.1.ip", strings.Replace(servers, "ip = \"b\"", "ip = \"x\"", 1)},
+		{"s[1].port", strings.Replace(servers, "ip = \"b\"\n", "ip = \"b\"\nport = \"x\"\n", 1)},
+		{"s[2].ip", servers + "\n[[s]]\nip = \"x\"\n"},
+		{"new.deep.key", strings.Replace(servers, "# name\n", "# name\nnew.deep.key = \"x\"\n", 1)},
+		{"t.c[0]", strings.Replace(servers, "[1, 2]", "[\"x\", 2]", 1)},
+		{"t.d.y", strings.Replace(servers, "{ x = 1 }", "{ x = 1, y = \"x\" }", 1)},
+	}
+	for _, c := range cases {
+		rs, err := Set(servers, c.path, xtype.NewStringObject("x"), &Options{Interpolation: &parser.Interpolation{}})
+		if err != nil || rs != c.expected {
+			t.Logf("set %s: expected %q, got %q %v\n", c.path, c.expected, rs, err)
+			t.Fail()
+		}
+	}
+
+	// 需要重新生成文档时，设置了变量展开等选项返回错误
+	table := xtype.NewMapObject(xtype.NewMap())
+	if rs, err := Set(servers, "s[0]", table, nil); err != nil || strings.Contains(rs, "#") {
+		t.Logf("set s[0]: expected a rewritten document, got %q %v\n", rs, err)
+		t.Fail()
+	}
+	if rs, err := Set(servers, "s[0]", table, &Options{Interpolation: &parser.Interpolation{}}); err == nil {
+		t.Logf("set s[0] with interpolation: expected error, got %q\n", rs)
+		t.Fail()
+	}
+}
+
+func TestProfile(t *testing.T) {
+	toml := `name = "app"
+debug = true
+hosts = ["a", "b"]
+[db]
+host = "localhost"
+port = 5432
+
+[profile.prod]
+debug = false
+hosts = ["c"]
+db = { host = "db.prod" }
+
+[env.prod.db]
+pool = 20
+
+[env.staging]
+debug = false
+`
+	cases := []struct {
+		profile  string
+		expected string
+	}{
+		{"prod", `{"name":"app","debug":false,"hosts":["c"],"db":{"host":"db.prod","port":5432,"pool":20}}`},
+		{"staging", `{"name":"app","debug":false,"hosts":["a","b"],"db":{"host":"localhost","port":5432}}`},
+	}
+	for _, c := range cases {
+		rs, err := ConvertWithOptions("json", "table", toml, &Options{Profile: c.profile})
+		if err != nil || rs != c.expected {
+			t.Logf("profile %s: expected %s, got %s %v\n", c.profile, c.expected, rs, err)
+			t.Fail()
+		}
+	}
+	_, err := ConvertWithOptions("json", "table", toml, &Options{Profile: "dev"})
+	if err == nil || err.Error() != `profile "dev" not found, available: env.prod, env.staging, profile.prod` {
+		t.Logf("expected profile not found error, got %v\n", err)
+		t.Fail()
+	}
+	// 未选择配置方案时按普通的表输出
+	if rs, _ := ConvertWithOptions("json", "table", "a = 1\n[env.dev]\na = 2\n", nil); rs != `{"a":1,"env":{"dev":{"a":2}}}` {
+		t.Logf("profiles should be opt-in, got %s\n", rs)
+		t.Fail()
+	}
+	rs, err := ConvertWithOptions("json", "table", "a = 1\n[stage.dev]\na = 2\n", &Options{Profile: "dev", ProfileKeys: []string{"stage"}})
+	if err != nil || rs != `{"a":2}` {
+		t.Logf("custom profile keys: unexpected %s %v\n", rs, err)
+		t.Fail()
+	}
+	// 只包含普通值的表按普通的表保留，不能与配置方案混合
+	rs, err = ConvertWithOptions("json", "table", "x = 1\n[env]\nPATH = \"/bin\"\n[profile.prod]\nx = 2\n", &Options{Profile: "prod"})
+	if err != nil || rs != `{"x":2,"env":{"PATH":"/bin"}}` {
+		t.Logf("env section: unexpected %s %v\n", rs, err)
+		t.Fail()
+	}
+	_, err = ConvertWithOptions("json", "table", "x = 1\n[env]\nPATH = \"/bin\"\n[env.prod]\nx = 2\n", &Options{File: "app.toml", Profile: "prod"})
+	if err == nil || err.Error() != "app.toml:3:8: env.PATH is not a profile table, env can not mix profiles (such as env.prod) and values" {
+		t.Logf("expected mixed profile error, got %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestYaml(t *testing.T) {
+	cases := []struct {
+		dataType string
+		toml     string
+		expected string
+	}{
+		{"single", `"hello"`, "hello\n"},
+		{"single", `"yes"`, "\"yes\"\n"},
+		{"single", "inf", ".inf\n"},
+		{"table", "b = 1\na = 'x'\n", "b: 1\na: x\n"},
+		{"table", "port = '8080'\nversion = '1.2.3'\nflag = 'on'\nempty = ''\nnote = 'a: b'\n",
+			"port: \"8080\"\nversion: \"1.2.3\"\nflag: \"on\"\nempty: \"\"\nnote: \"a: b\"\n"},
+		{"table", "text = \"\"\"\nline 1\nline 2\n\"\"\"\nraw = 'x\\ny'\n", "text: |\n  line 1\n  line 2\nraw: x\\ny\n"},
+		{"table", "[a]\ntext = \"line 1\\n  line 2\"\n", "a:\n  text: |-\n    line 1\n      line 2\n"},
+		{"table", "text = \"\\n  x\\ny\"\n", "text: |2-\n\n    x\n  y\n"},
+		{"table", "dob = 1979-05-27T07:32:00Z\nday = 1979-05-27\nat = 07:32:00\n", "dob: 1979-05-27T07:32:00Z\nday: 1979-05-27\nat: \"07:32:00\"\n"},
+		{"table", "a = [[1, 2], []]\nb = {}\n", "a:\n  - - 1\n    - 2\n  - []\nb: {}\n"},
+		{"table", "a = []\n", "a: []\n"},
+		{"table", "[[servers]]\nip = '10.0.0.1'\nroles = ['web']\n[[servers]]\nip = '10.0.0.2'\n",
+			"servers:\n  - ip: \"10.0.0.1\"\n    roles:\n      - web\n  - ip: \"10.0.0.2\"\n"},
+		{"table", "\"key: x\" = 1\n\"\" = 2\n", "\"key: x\": 1\n\"\": 2\n"},
+	}
+	for _, c := range cases {
+		rs, err := Yaml(c.dataType, c.toml)
+		if err != nil || rs != c.expected {
+			t.Logf("yaml %q: expected %q, got %q %v\n", c.toml, c.expected, rs, err)
+			t.Fail()
+		}
+	}
+}
@@ -3,6 +3,8 @@ package util
 import (
     "bytes"
     "regexp"
+    "strconv"
+    "strings"
 )
 
 // RuneInArray 判断给定的rune是否在数组中
@@ -29,31 +31,139 @@ func RunesContains(arr []rune, r rune) bool {
     return false
 }
 
-// IsNumeric 判断给定的字符串是否是数字
+// datetimePattern RFC 3339格式的日期时间（包括本地日期、本地时间）
+var datetimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[\+\-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)$`)
+
+// IsNumeric 判断给定的字符串是否是TOML格式的数字
+// 支持正负号、小数、指数、数字之间的下划线，0x/0o/0b前缀的十六进制、八进制、二进制整数，以及inf和nan
 func IsNumeric(str string) bool {
-    matched, err := regexp.MatchString(`^(\+|\-)?(0|[1-9]\d*)((\.\d+)?((e|E)(\+|\-)?[1-9]\d*)?)?$`, str)
-    if err != nil {
+    if len(str) > 2 && str[0] == '0' {
+        switch str[1] {
+        case 'x':
+            return isDigits(str[2:], 16)
+        case 'o':
+            return isDigits(str[2:], 8)
+        case 'b':
+            return isDigits(str[2:], 2)
+        }
+    }
+    s := str
+    if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+        s = s[1:]
+    }
+    if s == "inf" || s == "nan" {
+        return true
+    }
+    // 整数部分，除0本身外不能以0开头
+    i := scanDigits(s, 10)
+    if i == 0 || (s[0] == '0' && i > 1) {
         return false
     }
-    return matched
+    if i < len(s) && s[i] == '.' {
+        n := scanDigits(s[i+1:], 10)
+        if n == 0 {
+            return false
+        }
+        i += 1 + n
+    }
+    if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+        i++
+        if i < len(s) && (s[i] == '+' || s[i] == '-') {
+            i++
+        }
+        n := scanDigits(s[i:], 10)
+        if n == 0 {
+            return false
+        }
+        i += n
+    }
+    return i == len(s)
 }
 
 // IsPositiveIntNumeric 判断给定的数字是否是正整数
 func IsPositiveIntNumeric(str string) bool {
-    matched, err := regexp.MatchString(`^(0|[1-9]\d*)$`, str)
-    if err != nil {
-        return false
-    }
-    return matched
+    return IsNonNegativeInt(str)
 }
 
 // IsNonNegativeInt 判断给定的数字是否是非负整数
 func IsNonNegativeInt(str string) bool {
-    matched, err := regexp.MatchString(`^(0|[1-9]\d*)$`, str)
-    if err != nil {
+    if str == "" || (str[0] == '0' && len(str) > 1) {
         return false
     }
-    return matched
+    for i := 0; i < len(str); i++ {
+        if str[i] < '0' || str[i] > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// IsDatetime 判断给定的字符串是否是RFC 3339格式的日期时间（包括本地日期、本地时间）
+func IsDatetime(str string) bool {
+    return datetimePattern.MatchString(str)
+}
+
+// IsIntInRange 判断TOML格式的整数是否在int64范围内，浮点数始终返回true
+func IsIntInRange(str string) bool {
+    s := strings.ReplaceAll(strings.TrimPrefix(str, "+"), "_", "")
+    if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o' || s[1] == 'b') {
+        _, err := strconv.ParseInt(s, 0, 64)
+        return err == nil
+    }
+    if strings.ContainsAny(s, ".eEin") {
+        return true
+    }
+    _, err := strconv.ParseInt(s, 10, 64)
+    return err == nil
+}
+
+// NormalizeNumber 将TOML数字转换为通用的十进制形式
+// 去除正号以及下划线，十六进制、八进制、二进制整数转换为十进制
+func NormalizeNumber(str string) string {
+    s := strings.TrimPrefix(str, "+")
+    if strings.IndexByte(s, '_') >= 0 {
+        s = strings.ReplaceAll(s, "_", "")
+    }
+    if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o' || s[1] == 'b') {
+        if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+            return strconv.FormatInt(n, 10)
+        }
+    }
+    return s
+}
+
+// scanDigits 获取字符串开头的数字序列的长度，下划线两侧必须都是数字
+func scanDigits(s string, base int) int {
+    i := 0
+    for i < len(s) {
+        if isDigitOf(s[i], base) {
+            i++
+            continue
+        }
+        if s[i] == '_' && i > 0 && i+1 < len(s) && isDigitOf(s[i+1], base) {
+            i++
+            continue
+        }
+        break
+    }
+    return i
+}
+
+// isDigits 判断字符串是否是给定进制的数字序列
+func isDigits(s string, base int) bool {
+    return len(s) > 0 && scanDigits(s, base) == len(s)
+}
+
+func isDigitOf(c byte, base int) bool {
+    switch base {
+    case 2:
+        return c == '0' || c == '1'
+    case 8:
+        return c >= '0' && c <= '7'
+    case 16:
+        return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+    }
+    return c >= '0' && c <= '9'
 }
 
 // ParseTomlTableName Parses TOML table names and returns the hierarchy array of table names.
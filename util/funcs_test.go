@@ -0,0 +1,149 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestIsNumeric(t *testing.T) {
+	cases := map[string]bool{
+		"0":                     true,
+		"+99":                   true,
+		"-17":                   true,
+		"1_000":                 true,
+		"5_349_221":             true,
+		"3.1415":                true,
+		"-0.01":                 true,
+		"5e+22":                 true,
+		"1e06":                  true,
+		"-2E-2":                 true,
+		"6.626e-34":             true,
+		"9_224_617.445_991_228": true,
+		"0xDEADBEEF":            true,
+		"0xdead_beef":           true,
+		"0o755":                 true,
+		"0b1101_0110":           true,
+		"inf":                   true,
+		"+inf":                  true,
+		"-nan":                  true,
+		"":                      false,
+		"+":                     false,
+		"01":                    false,
+		"1__000":                false,
+		"_1000":                 false,
+		"1000_":                 false,
+		"1_.5":                  false,
+		".7":                    false,
+		"7.":                    false,
+		"3.e+20":                false,
+		"1e":                    false,
+		"1e_5":                  false,
+		"0x":                    false,
+		"+0xff":                 false,
+		"0o8":                   false,
+		"0b102":                 false,
+		"0X1F":                  false,
+		"Inf":                   false,
+		"1979-05-27":            false,
+		"1.2.3":                 false,
+	}
+	for str, expected := range cases {
+		if IsNumeric(str) != expected {
+			t.Logf("IsNumeric(%q): expected %v\n", str, expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestIsNonNegativeInt(t *testing.T) {
+	cases := map[string]bool{
+		"0":    true,
+		"7":    true,
+		"1234": true,
+		"":     false,
+		"00":   false,
+		"012":  false,
+		"-1":   false,
+		"+1":   false,
+		"1_0":  false,
+		"1.0":  false,
+	}
+	for str, expected := range cases {
+		if IsNonNegativeInt(str) != expected || IsPositiveIntNumeric(str) != expected {
+			t.Logf("IsNonNegativeInt(%q): expected %v\n", str, expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	cases := map[string]string{
+		"+99":         "99",
+		"-17":         "-17",
+		"1_000":       "1000",
+		"5e+22":       "5e+22",
+		"-2E-2":       "-2E-2",
+		"0xDEADBEEF":  "3735928559",
+		"0xdead_beef": "3735928559",
+		"0o755":       "493",
+		"0b11010110":  "214",
+		"+inf":        "inf",
+	}
+	for str, expected := range cases {
+		if n := NormalizeNumber(str); n != expected {
+			t.Logf("NormalizeNumber(%q): expected %s, got %s\n", str, expected, n)
+			t.Fail()
+		}
+	}
+}
+
+var benchmarkNumbers = []string{"0", "738594937", "-17", "1_000_000", "6.626e-34", "0xdead_beef", "-inf", "1979-05-27", "key"}
+
+func BenchmarkIsNumeric(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, str := range benchmarkNumbers {
+			IsNumeric(str)
+		}
+	}
+}
+
+func BenchmarkIsPositiveIntNumeric(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, str := range benchmarkNumbers {
+			IsPositiveIntNumeric(str)
+		}
+	}
+}
+
+func BenchmarkIsNonNegativeInt(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, str := range benchmarkNumbers {
+			IsNonNegativeInt(str)
+		}
+	}
+}
+
+func TestIsIntInRange(t *testing.T) {
+	cases := map[string]bool{
+		"9_223_372_036_854_775_807": true,
+		"-9223372036854775808":      true,
+		"9223372036854775808":       false,
+		"-9223372036854775809":      false,
+		"+99999999999999999999":     false,
+		"0x7FFF_FFFF_FFFF_FFFF":     true,
+		"0xFFFFFFFFFFFFFFFF":        false,
+		"0o1777777777777777777777":  false,
+		"1e400":                     true,
+		"99999999999999999999.5":    true,
+		"inf":                       true,
+		"-nan":                      true,
+	}
+	for str, expected := range cases {
+		if IsIntInRange(str) != expected {
+			t.Logf("IsIntInRange(%q): expected %v\n", str, expected)
+			t.Fail()
+		}
+	}
+}
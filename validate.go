@@ -0,0 +1,87 @@
+package toml2x
+
+import (
+    "errors"
+    "fmt"
+
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/parser"
+    "github.com/whencome/toml2x/schema"
+)
+
+// Issue 校验时发现的问题
+type Issue struct {
+    File    string `json:"file"`
+    Line    int    `json:"line"`   // 行号，为0时表示问题与具体位置无关
+    Column  int    `json:"column"` // 列号
+    Message string `json:"message"`
+}
+
+// String 以 file:line:col: message 的形式输出
+func (i Issue) String() string {
+    switch {
+    case i.Line <= 0 && i.File == "":
+        return i.Message
+    case i.Line <= 0:
+        return fmt.Sprintf("%s: %s", i.File, i.Message)
+    case i.File == "":
+        return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+    }
+    return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+}
+
+// Validate 校验toml配置内容而不进行转换，返回发现的所有问题，没有问题时返回空列表
+// opts.File 用于问题中的文件名，opts.Limits 用于限制不可信的输入；
+// 设置了opts.Schema并且没有语法错误时，同时报告不满足schema的值
+func Validate(toml string, opts *Options) []Issue {
+    if opts == nil {
+        opts = &Options{}
+    }
+    errs := parser.Validate(toml, opts.parserOptions())
+    issues := make([]Issue, 0, len(errs))
+    for _, err := range errs {
+        issues = append(issues, NewIssue(opts.File, err))
+    }
+    if len(issues) > 0 || opts.Schema == nil {
+        return issues
+    }
+    obj, err := parseWithOptions("table", toml, opts)
+    if err != nil {
+        return append(issues, NewIssue(opts.File, err))
+    }
+    for _, v := range opts.Schema.ValidateRedacted(obj, opts.secret()) {
+        issues = append(issues, violationIssue(opts.File, v))
+    }
+    return issues
+}
+
+// violationIssue 将不满足schema的值转换为问题
+func violationIssue(file string, v schema.Violation) Issue {
+    msg := v.Message
+    if v.Path != "" {
+        msg = v.Path + ": " + msg
+    }
+    return Issue{File: errorFile(v.Pos.File, file), Line: v.Pos.Line, Column: v.Pos.Column, Message: msg}
+}
+
+// NewIssue 根据错误创建问题，语法错误以及超出资源限制的错误会保留位置
+// 错误发生在被包含的文件中时，使用错误中记录的文件名
+func NewIssue(file string, err error) Issue {
+    var syntaxErr *parser.SyntaxError
+    var limitErr *formatter.LimitError
+    switch {
+    case errors.As(err, &syntaxErr):
+        return Issue{File: errorFile(syntaxErr.Pos.File, file), Line: syntaxErr.Pos.Line, Column: syntaxErr.Pos.Column, Message: syntaxErr.Msg}
+    case errors.As(err, &limitErr):
+        return Issue{File: errorFile(limitErr.File, file), Line: limitErr.Line, Column: limitErr.Column, Message: limitErr.Message()}
+    }
+    return Issue{File: file, Message: err.Error()}
+}
+
+// errorFile 优先使用错误中记录的文件名
+func errorFile(name string, file string) string {
+    if name != "" {
+        return name
+    }
+    return file
+}
@@ -17,12 +17,8 @@ func (o *Object) Json(scalar bool) string {
     case TypeBoolean:
         return util.String(o.Value)
     case TypeNumber:
-        v := util.String(o.Value)
-        if strings.HasPrefix(v, "+") {
-            v = string([]rune(v)[1:])
-        }
-        return v
-    case TypeString:
+        return jsonNumber(util.String(o.Value))
+    case TypeString, TypeDatetime:
         if scalar {
             return util.String(o.Value)
         }
@@ -42,12 +38,8 @@ func (o *Object) Xml() string {
     case TypeBoolean:
         return "<xml><single>" + util.String(o.Value) + "</single></xml>"
     case TypeNumber:
-        v := util.String(o.Value)
-        if strings.HasPrefix(v, "+") {
-            v = string([]rune(v)[1:])
-        }
-        return "<xml><single>" + v + "</single></xml>"
-    case TypeString:
+        return "<xml><single>" + util.NormalizeNumber(util.String(o.Value)) + "</single></xml>"
+    case TypeString, TypeDatetime:
         return "<xml><single><![CDATA[" + util.String(o.Value) + "]]></single></xml>"
     case TypeMap:
         return "<xml><table>" + o.Value.(*Map).Xml() + "</table></xml>"
@@ -64,12 +56,8 @@ func (o *Object) Php() string {
     case TypeBoolean:
         return util.String(o.Value)
     case TypeNumber:
-        v := util.String(o.Value)
-        if strings.HasPrefix(v, "+") {
-            v = string([]rune(v)[1:])
-        }
-        return v
-    case TypeString:
+        return phpNumber(util.String(o.Value))
+    case TypeString, TypeDatetime:
         return formatter.FmtPhpString(util.String(o.Value))
     case TypeMap:
         return o.Value.(*Map).Php(0)
@@ -129,17 +117,13 @@ func (m *Map) Xml() string {
         switch v.Type {
         case TypeBoolean:
             fallthrough
-        case TypeString:
+        case TypeString, TypeDatetime:
             buf.WriteString("<![CDATA[")
             buf.WriteString(util.String(v.Value))
             buf.WriteString("]]>")
         case TypeNumber:
-            n := util.String(v)
-            if strings.HasPrefix(n, "+") {
-                n = string([]rune(n)[1:])
-            }
             buf.WriteString("<![CDATA[")
-            buf.WriteString(util.String(v.Value))
+            buf.WriteString(util.NormalizeNumber(util.String(v.Value)))
             buf.WriteString("]]>")
         case TypeMap:
             buf.WriteString(v.Value.(*Map).Xml())
@@ -175,15 +159,11 @@ func (m *Map) Php(depth int) string {
         case TypeBoolean:
             buf.WriteString(util.String(v.Value))
             buf.WriteString(",\n")
-        case TypeString:
+        case TypeString, TypeDatetime:
             buf.WriteString(formatter.FmtPhpString(util.String(v.Value)))
             buf.WriteString(",\n")
         case TypeNumber:
-            n := util.String(v.Value)
-            if strings.HasPrefix(n, "+") {
-                n = string([]rune(n)[1:])
-            }
-            buf.WriteString(n)
+            buf.WriteString(phpNumber(util.String(v.Value)))
             buf.WriteString(",\n")
         case TypeMap:
             buf.WriteString(v.Value.(*Map).Php(depth + 1))
@@ -198,3 +178,26 @@ func (m *Map) Php(depth int) string {
     }
     return buf.String()
 }
+
+// jsonNumber 格式化json数字，json不支持inf以及nan，输出为null
+func jsonNumber(v string) string {
+    n := util.NormalizeNumber(v)
+    if strings.HasSuffix(n, "inf") || strings.HasSuffix(n, "nan") {
+        return "null"
+    }
+    return n
+}
+
+// phpNumber 格式化php数字，inf以及nan使用php的INF、NAN常量
+func phpNumber(v string) string {
+    n := util.NormalizeNumber(v)
+    switch n {
+    case "inf":
+        return "INF"
+    case "-inf":
+        return "-INF"
+    case "nan", "-nan":
+        return "NAN"
+    }
+    return n
+}
@@ -0,0 +1,254 @@
+package xtype
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+
+    "github.com/whencome/toml2x/util"
+)
+
+// Set 按路径设置值，键已存在时保持其位置不变，缺失的上级表会自动创建
+// 数组中的下标必须已存在，或者等于数组长度（追加到末尾）
+func (m *Map) Set(path string, obj *Object) error {
+    keys, err := parseMutationPath(path)
+    if err != nil {
+        return err
+    }
+    dst := m
+    for i, key := range keys {
+        k := dst.GetKey(key)
+        if k == nil {
+            k, err = dst.newChildKey(key)
+            if err != nil {
+                return fmt.Errorf("%s: %s", path, err)
+            }
+            if i == len(keys)-1 {
+                dst.insertKey(len(dst.Keys), k, obj)
+                return nil
+            }
+            dst.insertKey(len(dst.Keys), k, NewMapObject(NewMap()))
+        }
+        if i == len(keys)-1 {
+            dst.Data[k] = obj
+            return nil
+        }
+        v := dst.Data[k]
+        if v == nil || v.Type != TypeMap {
+            return fmt.Errorf("%s: %s is not a table or an array", path, k.Value)
+        }
+        dst = v.Value.(*Map)
+    }
+    return nil
+}
+
+// Delete 按路径删除值，删除数组元素后其后的元素下标依次前移
+func (m *Map) Delete(path string) error {
+    parent, pos, err := m.locate(path)
+    if err != nil {
+        return err
+    }
+    isArr := parent.IsArray()
+    parent.removeKey(pos)
+    if isArr {
+        // 删除所有元素之后仍然是空数组
+        parent.array = true
+        parent.reindex()
+    }
+    return nil
+}
+
+// Rename 重命名路径所指向的键，键的位置以及值保持不变
+func (m *Map) Rename(path string, name string) error {
+    parent, pos, err := m.locate(path)
+    if err != nil {
+        return err
+    }
+    if parent.IsArray() {
+        return fmt.Errorf("%s: array elements can not be renamed", path)
+    }
+    if k := parent.GetKey(name); k != nil {
+        if k == parent.Keys[pos] {
+            return nil
+        }
+        return fmt.Errorf("%s: key %s already exists", path, name)
+    }
+    k := parent.Keys[pos]
+    obj := parent.Data[k]
+    parent.removeKey(pos)
+    nk := &Key{Value: name, IsNumeric: util.IsPositiveIntNumeric(name), Pos: k.Pos}
+    parent.insertKey(pos, nk, obj)
+    return nil
+}
+
+// Move 将路径所指向的键移动到所在表中的第index个位置，数组元素移动后重新编号
+func (m *Map) Move(path string, index int) error {
+    parent, pos, err := m.locate(path)
+    if err != nil {
+        return err
+    }
+    if index < 0 || index >= len(parent.Keys) {
+        return fmt.Errorf("%s: position %d out of range", path, index)
+    }
+    isArr := parent.IsArray()
+    k := parent.Keys[pos]
+    obj := parent.Data[k]
+    parent.removeKey(pos)
+    parent.insertKey(index, k, obj)
+    if isArr {
+        parent.reindex()
+    }
+    return nil
+}
+
+// InsertBefore 在路径所指向的键之前插入键值对，所在的容器为数组时忽略key，插入元素后重新编号
+func (m *Map) InsertBefore(path string, key string, obj *Object) error {
+    return m.insertAt(path, 0, key, obj)
+}
+
+// InsertAfter 在路径所指向的键之后插入键值对，所在的容器为数组时忽略key，插入元素后重新编号
+func (m *Map) InsertAfter(path string, key string, obj *Object) error {
+    return m.insertAt(path, 1, key, obj)
+}
+
+// Append 向路径所指向的数组末尾追加元素，路径不存在时创建新的数组
+func (m *Map) Append(path string, obj *Object) error {
+    keys, err := parseMutationPath(path)
+    if err != nil {
+        return err
+    }
+    arr := NewArrayMap()
+    if matched := NewMapObject(m).query(keys); len(matched) > 0 {
+        v := matched[0]
+        if v.Type != TypeMap || (len(v.Value.(*Map).Keys) > 0 && !v.Value.(*Map).IsArray()) {
+            return fmt.Errorf("%s: not an array", path)
+        }
+        arr = v.Value.(*Map)
+    } else if err := m.Set(path, NewMapObject(arr)); err != nil {
+        return err
+    }
+    arr.insertKey(len(arr.Keys), NewNumberKey(strconv.Itoa(len(arr.Keys))), obj)
+    return nil
+}
+
+// insertAt 在路径所指向的键之前（offset为0）或者之后（offset为1）插入键值对
+func (m *Map) insertAt(path string, offset int, key string, obj *Object) error {
+    parent, pos, err := m.locate(path)
+    if err != nil {
+        return err
+    }
+    if parent.IsArray() {
+        parent.insertKey(pos+offset, NewNumberKey(""), obj)
+        parent.reindex()
+        return nil
+    }
+    if parent.GetKey(key) != nil {
+        return fmt.Errorf("%s: key %s already exists", path, key)
+    }
+    k := NewStringKey(key)
+    if util.IsPositiveIntNumeric(key) {
+        k = NewNumberKey(key)
+    }
+    parent.insertKey(pos+offset, k, obj)
+    return nil
+}
+
+// locate 获取路径所指向的键所在的表，以及键在表中的位置
+func (m *Map) locate(path string) (*Map, int, error) {
+    keys, err := parseMutationPath(path)
+    if err != nil {
+        return nil, -1, err
+    }
+    parent := m
+    if len(keys) > 1 {
+        matched := NewMapObject(m).query(keys[:len(keys)-1])
+        if len(matched) == 0 || matched[0].Type != TypeMap {
+            return nil, -1, fmt.Errorf("%s: %w", path, ErrNotFound)
+        }
+        parent = matched[0].Value.(*Map)
+    }
+    if k := parent.GetKey(keys[len(keys)-1]); k != nil {
+        for i, ek := range parent.Keys {
+            if ek == k {
+                return parent, i, nil
+            }
+        }
+    }
+    return nil, -1, fmt.Errorf("%s: %w", path, ErrNotFound)
+}
+
+// newChildKey 创建新的键，数组只能在末尾追加元素
+func (m *Map) newChildKey(key string) (*Key, error) {
+    if m.IsArray() {
+        if key != strconv.Itoa(len(m.Keys)) {
+            return nil, fmt.Errorf("index %s out of range", key)
+        }
+        return NewNumberKey(key), nil
+    }
+    if util.IsPositiveIntNumeric(key) {
+        return NewNumberKey(key), nil
+    }
+    return NewStringKey(key), nil
+}
+
+// insertKey 在第pos个位置插入键值对
+func (m *Map) insertKey(pos int, k *Key, obj *Object) {
+    if m.Data == nil {
+        m.Data = make(map[*Key]*Object, 0)
+    }
+    m.appendKey(k)
+    copy(m.Keys[pos+1:], m.Keys[pos:])
+    m.Keys[pos] = k
+    m.Data[k] = obj
+}
+
+// removeKey 删除第pos个键值对
+func (m *Map) removeKey(pos int) {
+    valid := m.indexValid()
+    k := m.Keys[pos]
+    m.Keys = append(m.Keys[:pos], m.Keys[pos+1:]...)
+    delete(m.Data, k)
+    if !valid {
+        m.index = nil
+        return
+    }
+    if m.index[k.Value] == k {
+        delete(m.index, k.Value)
+        // 同名的键（直接修改Keys产生）重新加入索引
+        for _, ek := range m.Keys {
+            if ek.Value == k.Value {
+                m.index[k.Value] = ek
+                break
+            }
+        }
+    }
+    m.indexed = len(m.Keys)
+}
+
+// reindex 按当前顺序为数组元素重新编号
+func (m *Map) reindex() {
+    m.index = make(map[string]*Key, len(m.Keys))
+    for i, k := range m.Keys {
+        k.Value = strconv.Itoa(i)
+        k.IsNumeric = true
+        m.index[k.Value] = k
+    }
+    m.indexed = len(m.Keys)
+}
+
+// parseMutationPath 解析修改操作的路径，路径不能为空，也不能包含通配符
+func parseMutationPath(path string) ([]string, error) {
+    keys, err := ParsePath(path)
+    if err != nil {
+        return nil, err
+    }
+    if len(keys) == 0 {
+        return nil, errors.New("empty path")
+    }
+    for _, k := range keys {
+        if k == Wildcard {
+            return nil, errors.New("wildcard is not allowed in path: " + path)
+        }
+    }
+    return keys, nil
+}
@@ -0,0 +1,74 @@
+package xtype_test
+
+import (
+	"testing"
+
+	"github.com/whencome/toml2x/xtype"
+)
+
+func TestMutation(t *testing.T) {
+	obj := parseTable(t, `name = "demo"
+debug = true
+ports = [8000, 8001, 8002]
+[database]
+host = "localhost"
+port = 5432`)
+	m := obj.Value.(*xtype.Map)
+
+	steps := []error{
+		m.Set("database.user", xtype.NewStringObject("admin")),
+		m.Set("database.host", xtype.NewStringObject("db.internal")),
+		m.Set("cache.redis.size", xtype.NewNumberObject("128")),
+		m.Delete("debug"),
+		m.Delete("ports[0]"),
+		m.Append("ports", xtype.NewNumberObject("8003")),
+		m.InsertBefore("ports[0]", "", xtype.NewNumberObject("7999")),
+		m.Rename("database.port", "db_port"),
+		m.Move("database.user", 0),
+		m.InsertAfter("name", "version", xtype.NewStringObject("1.0")),
+		m.Append("tags", xtype.NewStringObject("web")),
+		m.Set("ports[4]", xtype.NewNumberObject("8004")),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Logf("mutation step %d failed: %s\n", i, err)
+			t.Fail()
+		}
+	}
+	expected := `{"name":"demo","version":"1.0","ports":[7999,8001,8002,8003,8004],"database":{"user":"admin","host":"db.internal","db_port":5432},"cache":{"redis":{"size":128}},"tags":["web"]}`
+	if rs := obj.Json(true); rs != expected {
+		t.Logf("unexpected result: %s\n", rs)
+		t.Fail()
+	}
+
+	// 删除所有元素之后仍然是数组
+	servers := parseTable(t, "[[servers]]\nip = \"a\"\n[[servers]]\nip = \"b\"\nports = [1]\n")
+	sm := servers.Value.(*xtype.Map)
+	if err := sm.Delete("servers[1].ports[0]"); err != nil {
+		t.Fatalf("delete servers[1].ports[0] failed: %s\n", err)
+	}
+	for sm.Delete("servers[0]") == nil {
+	}
+	if rs := servers.Json(true); rs != `{"servers":[]}` {
+		t.Logf("unexpected result after deleting all elements: %s\n", rs)
+		t.Fail()
+	}
+
+	// 错误处理
+	failures := []error{
+		m.Set("ports[9]", xtype.NewNumberObject("1")),
+		m.Set("name.first", xtype.NewStringObject("x")),
+		m.Delete("missing"),
+		m.Rename("ports[0]", "first"),
+		m.Rename("database.user", "host"),
+		m.Move("name", 10),
+		m.Append("database", xtype.NewStringObject("x")),
+		m.Set("servers.*.ip", xtype.NewStringObject("x")),
+	}
+	for i, err := range failures {
+		if err == nil {
+			t.Logf("invalid mutation %d should fail\n", i)
+			t.Fail()
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package xtype
 
 import (
+    "fmt"
     "sort"
     "strconv"
 
@@ -15,20 +16,54 @@ const (
     TypeNumber = iota
     TypeBoolean
     TypeString
-    TypeMap   // key-value 值
-    TypeArray // array
+    TypeMap      // key-value 值
+    TypeArray    // array
+    TypeDatetime // 日期时间，RFC 3339格式
 )
 
+// Position 定义键或者值在toml源文件中的位置
+type Position struct {
+    File   string // 文件名，可能为空
+    Line   int    // 行号，从1开始
+    Column int    // 列号，从1开始，按字符计算
+}
+
 // Object define a scalar object which save only a single value
 type Object struct {
     Value interface{}
-    Type  int // indicate the value type, can be int,float,string,bool,array or map
+    Type  int      // indicate the value type, can be int,float,string,bool,array or map
+    Pos   Position // 值在源文件中的位置
 }
 
 // Key Define the key of a map
 type Key struct {
     Value     string
-    IsNumeric bool // 键值是否是数字
+    IsNumeric bool     // 键值是否是数字
+    Pos       Position // 键在源文件中的位置
+}
+
+// IsValid 判断位置信息是否有效
+func (p Position) IsValid() bool {
+    return p.Line > 0
+}
+
+// String 返回 file:line:col 形式的位置描述
+func (p Position) String() string {
+    if !p.IsValid() {
+        return p.File
+    }
+    if p.File == "" {
+        return fmt.Sprintf("%d:%d", p.Line, p.Column)
+    }
+    return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Position 获取值在源文件中的位置
+func (o *Object) Position() Position {
+    if o == nil {
+        return Position{}
+    }
+    return o.Pos
 }
 
 func (k *Key) String() string {
@@ -39,9 +74,16 @@ func (k *Key) String() string {
 }
 
 // Map Define a map struct
+// Map 是按插入顺序保存的有序表，Keys记录键的顺序，Data保存键对应的值，
+// 同时维护键名到键的索引，按名称查找键的时间复杂度为O(1)。
+// 应尽量通过方法修改Map，直接修改Keys后按名称查找会退化为逐个比较，直到下次通过方法修改时重建索引。
+// 读取操作不会修改Map，多个goroutine可以并发读取同一个Map，并发修改则需要调用方自行加锁
 type Map struct {
-    Keys []*Key
-    Data map[*Key]*Object
+    Keys    []*Key
+    Data    map[*Key]*Object
+    index   map[string]*Key // 键名索引
+    indexed int             // 建立索引时Keys的长度，用于判断索引是否有效
+    array   bool            // 是否是数组，用于区分空数组与空表
 }
 
 // Array Define an array
@@ -79,6 +121,14 @@ func NewStringObject(val string) *Object {
     }
 }
 
+// NewDatetimeObject create a datetime object
+func NewDatetimeObject(val string) *Object {
+    return &Object{
+        Value: val,
+        Type:  TypeDatetime,
+    }
+}
+
 func NewMapObject(val *Map) *Object {
     return &Object{
         Value: val,
@@ -109,11 +159,19 @@ func NewStringKey(v string) *Key {
 
 func NewMap() *Map {
     return &Map{
-        Keys: make([]*Key, 0),
-        Data: make(map[*Key]*Object, 0),
+        Keys:  make([]*Key, 0),
+        Data:  make(map[*Key]*Object, 0),
+        index: make(map[string]*Key),
     }
 }
 
+// NewArrayMap 创建以数组形式保存的Map，为空时IsArray也返回true
+func NewArrayMap() *Map {
+    m := NewMap()
+    m.array = true
+    return m
+}
+
 // NewArray Create a new empty array
 func NewArray() *Array {
     return &Array{
@@ -131,20 +189,59 @@ func (arr *Array) Add(i int, obj *Object) {
 
 // GetKey 判断给定的key是否存在
 func (m *Map) GetKey(k string) *Key {
-    if len(m.Keys) == 0 {
-        return nil
+    if m.indexValid() {
+        return m.index[k]
     }
-    for _, ek := range m.Keys {
-        if ek.Value == k {
-            return ek
+    // 索引失效时不在读取过程中重建，以保证并发读取安全
+    for _, key := range m.Keys {
+        if key.Value == k {
+            return key
         }
     }
     return nil
 }
 
+// indexValid 判断索引与Keys是否一致
+func (m *Map) indexValid() bool {
+    return m.index != nil && m.indexed == len(m.Keys)
+}
+
+// keyIndex 获取键名索引，索引缺失或者与Keys不一致时重新创建，只能在修改Map时调用
+func (m *Map) keyIndex() map[string]*Key {
+    if !m.indexValid() {
+        m.index = make(map[string]*Key, len(m.Keys))
+        for _, k := range m.Keys {
+            if _, ok := m.index[k.Value]; !ok {
+                m.index[k.Value] = k
+            }
+        }
+        m.indexed = len(m.Keys)
+    }
+    return m.index
+}
+
+// appendKey 将新的键添加到末尾，并更新索引
+func (m *Map) appendKey(k *Key) {
+    idx := m.keyIndex()
+    m.Keys = append(m.Keys, k)
+    if _, ok := idx[k.Value]; !ok {
+        idx[k.Value] = k
+    }
+    m.indexed = len(m.Keys)
+}
+
+// KeyPosition 获取键在源文件中的位置，键不存在时返回无效的位置
+func (m *Map) KeyPosition(k string) Position {
+    key := m.GetKey(k)
+    if key == nil {
+        return Position{}
+    }
+    return key.Pos
+}
+
 func (m *Map) Add(k *Key, obj *Object) {
     if _, ok := m.Data[k]; !ok {
-        m.Keys = append(m.Keys, k)
+        m.appendKey(k)
     }
     m.Data[k] = obj
 }
@@ -163,7 +260,7 @@ func (m *Map) DeepAdd(fields []string, obj *Object) {
             } else {
                 k = NewStringKey(field)
             }
-            dst.Keys = append(dst.Keys, k)
+            dst.appendKey(k)
         }
         if dst.Data == nil {
             dst.Data = make(map[*Key]*Object, 0)
@@ -184,29 +281,44 @@ func (m *Map) DeepAdd(fields []string, obj *Object) {
     }
 }
 
-// Merge 合并对象
+// Merge 合并对象，同名的键按名称合并
+// 两侧都是表时递归合并，否则（包括数组）使用m1中的值替换，m1中的值不会被复制
 func (m *Map) Merge(m1 *Map) {
     if m1 == nil {
         return
     }
     dst := m
+    if dst.Data == nil {
+        dst.Data = make(map[*Key]*Object, 0)
+    }
     for _, k := range m1.Keys {
-        if _, ok := dst.Data[k]; !ok {
-            dst.Keys = append(dst.Keys, k)
-            dst.Data[k] = NewMapObject(NewMap())
+        src := m1.Data[k]
+        dk := dst.GetKey(k.Value)
+        if dk == nil {
+            dst.appendKey(k)
+            dst.Data[k] = src
+            continue
         }
-        if m1.Data[k].Type == TypeMap {
-            dst.Data[k].Value.(*Map).Merge(m1.Data[k].Value.(*Map))
+        if isTable(src) && isTable(dst.Data[dk]) {
+            dst.Data[dk].Value.(*Map).Merge(src.Value.(*Map))
         } else {
-            dst.Data[k] = m1.Data[k]
+            dst.Data[dk] = src
         }
     }
 }
 
-// IsArray 判断map对象是数组还是对象
+// isTable 判断对象是否是表，空的Map视为表
+func isTable(o *Object) bool {
+    if o == nil || o.Type != TypeMap {
+        return false
+    }
+    return !o.Value.(*Map).IsArray()
+}
+
+// IsArray 判断map对象是数组还是对象，空的Map只有通过NewArrayMap创建时才是数组
 func (m *Map) IsArray() bool {
     if len(m.Keys) == 0 {
-        return false
+        return m.array
     }
     numberKeys := make([]int, 0)
     for _, k := range m.Keys {
@@ -256,18 +368,8 @@ func (m *Map) GetRecursiveIndexedKeys(keys []string) []string {
             fData := dst.Data[k].Value.(*Map)
             // 计算索引
             j := 0
-            for {
-                found := false
-                for _, ek := range fData.Keys {
-                    if ek.Value == strconv.Itoa(j) {
-                        j++
-                        found = true
-                        break
-                    }
-                }
-                if !found {
-                    break
-                }
+            for fData.GetKey(strconv.Itoa(j)) != nil {
+                j++
             }
             idx = j
             break
@@ -277,23 +379,11 @@ func (m *Map) GetRecursiveIndexedKeys(keys []string) []string {
             // key已经存在，且是一个map，继续寻找下级
             dst = dst.Data[k].Value.(*Map)
             // 计算索引
-            j := 0
             lastIdx := -1
             var lastK *Key
-            for {
-                found := false
-                for _, ek := range dst.Keys {
-                    if ek.Value == strconv.Itoa(j) {
-                        lastIdx = j
-                        lastK = ek
-                        j++
-                        found = true
-                        break
-                    }
-                }
-                if !found {
-                    break
-                }
+            for ek := dst.GetKey("0"); ek != nil; ek = dst.GetKey(strconv.Itoa(lastIdx + 1)) {
+                lastIdx++
+                lastK = ek
             }
             if lastIdx >= 0 {
                 if dst.Data[lastK].Type == TypeMap {
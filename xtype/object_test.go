@@ -0,0 +1,120 @@
+package xtype
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// wideMap 创建包含n个键的表
+func wideMap(n int) *Map {
+	m := NewMap()
+	for i := 0; i < n; i++ {
+		m.DeepAdd([]string{"flag_" + strconv.Itoa(i)}, NewBoolObject("true"))
+	}
+	return m
+}
+
+func TestKeyIndex(t *testing.T) {
+	m := wideMap(100)
+	if k := m.GetKey("flag_42"); k == nil || k != m.Keys[42] {
+		t.Log("GetKey should find existing key\n")
+		t.Fail()
+	}
+	// 直接修改Keys后索引应自动重建
+	k := NewStringKey("direct")
+	m.Keys = append(m.Keys, k)
+	m.Data[k] = NewBoolObject("false")
+	if m.GetKey("direct") != k {
+		t.Log("index should be rebuilt after Keys is modified directly\n")
+		t.Fail()
+	}
+	if err := m.Delete("flag_0"); err != nil || m.GetKey("flag_0") != nil {
+		t.Logf("deleted key should be removed from index: %v\n", err)
+		t.Fail()
+	}
+	if err := m.Rename("flag_1", "renamed"); err != nil || m.GetKey("flag_1") != nil || m.GetKey("renamed") == nil {
+		t.Logf("renamed key should be reindexed: %v\n", err)
+		t.Fail()
+	}
+
+	// 同名的键按名称合并
+	dst := wideMap(3)
+	dst.Merge(wideMap(5))
+	if len(dst.Keys) != 5 {
+		t.Logf("merge should not duplicate keys, got %d keys\n", len(dst.Keys))
+		t.Fail()
+	}
+}
+
+// TestConcurrentRead 多个goroutine并发读取同一个对象，需要使用 -race 运行
+func TestConcurrentRead(t *testing.T) {
+	m := wideMap(100)
+	// 直接修改Keys使索引失效，读取时不应重建索引
+	k := NewStringKey("direct")
+	m.Keys = append(m.Keys, k)
+	m.Data[k] = NewBoolObject("false")
+	obj := NewMapObject(NewMap())
+	obj.Value.(*Map).Add(NewStringKey("flags"), NewMapObject(m))
+
+	expected := obj.Json(true)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if m.GetKey("direct") != k || m.GetKey("flag_"+strconv.Itoa(j)) == nil {
+					t.Log("GetKey failed during concurrent read\n")
+					t.Fail()
+				}
+				if _, err := obj.GetBool("flags.flag_" + strconv.Itoa(i)); err != nil {
+					t.Logf("GetBool failed during concurrent read: %s\n", err)
+					t.Fail()
+				}
+				if obj.Json(true) != expected || obj.Xml() == "" || obj.Php() == "" || obj.Toml(true) == "" {
+					t.Log("output changed during concurrent read\n")
+					t.Fail()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkDeepAddWide(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wideMap(20000)
+	}
+}
+
+func BenchmarkGetKeyWide(b *testing.B) {
+	m := wideMap(20000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if m.GetKey("flag_19999") == nil {
+			b.Fatal("key not found")
+		}
+	}
+}
+
+func BenchmarkGetRecursiveIndexedKeys(b *testing.B) {
+	m := NewMap()
+	for i := 0; i < 5000; i++ {
+		keys := m.GetRecursiveIndexedKeys([]string{"products"})
+		m.DeepAdd(append(keys, "name"), NewStringObject("item"))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.GetRecursiveIndexedKeys([]string{"products"})
+	}
+}
+
+func BenchmarkJsonWide(b *testing.B) {
+	m := wideMap(20000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Json()
+	}
+}
@@ -0,0 +1,260 @@
+package xtype
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/whencome/toml2x/util"
+)
+
+// ErrNotFound 路径对应的值不存在
+var ErrNotFound = errors.New("key not found")
+
+// Wildcard 路径中匹配任意键或者任意数组元素的通配符
+const Wildcard = "*"
+
+// TypeName 获取数据类型的名称
+func TypeName(t int) string {
+    switch t {
+    case TypeNumber:
+        return "number"
+    case TypeBoolean:
+        return "boolean"
+    case TypeString:
+        return "string"
+    case TypeMap:
+        return "table"
+    case TypeArray:
+        return "array"
+    case TypeDatetime:
+        return "datetime"
+    }
+    return "unknown"
+}
+
+// ParsePath 解析查询路径，返回各级键名
+// 路径使用点号分隔，如 servers.alpha.ip；数组下标可以写作 fruits[0].name 或者 fruits.0.name；
+// 包含特殊字符的键可以使用双引号，如 site."google.com"；* 匹配任意键或数组元素
+func ParsePath(path string) ([]string, error) {
+    keys := make([]string, 0)
+    chars := []rune(strings.TrimSpace(path))
+    size := len(chars)
+    if size == 0 {
+        return keys, nil
+    }
+    buf := strings.Builder{}
+    // 当前段是否已结束（引号或下标之后只能是分隔符）
+    closed := false
+    for i := 0; i < size; i++ {
+        switch c := chars[i]; {
+        case c == '.':
+            if buf.Len() == 0 && !closed {
+                return nil, errors.New("empty key in path: " + path)
+            }
+            if !closed {
+                keys = append(keys, buf.String())
+            }
+            buf.Reset()
+            closed = false
+        case c == '"' && buf.Len() == 0 && !closed:
+            j := i + 1
+            for ; j < size && chars[j] != '"'; j++ {
+                if chars[j] == '\\' && j+1 < size {
+                    j++
+                }
+                buf.WriteRune(chars[j])
+            }
+            if j >= size {
+                return nil, errors.New("missing closing quote in path: " + path)
+            }
+            keys = append(keys, buf.String())
+            buf.Reset()
+            closed = true
+            i = j
+        case c == '[':
+            if buf.Len() > 0 {
+                keys = append(keys, buf.String())
+                buf.Reset()
+            } else if !closed {
+                return nil, errors.New("missing key before index in path: " + path)
+            }
+            end := i + 1
+            for end < size && chars[end] != ']' {
+                end++
+            }
+            if end >= size {
+                return nil, errors.New("missing closing bracket in path: " + path)
+            }
+            idx := strings.TrimSpace(string(chars[i+1 : end]))
+            if idx != Wildcard && !util.IsNonNegativeInt(idx) {
+                return nil, errors.New("invalid index in path: " + path)
+            }
+            keys = append(keys, idx)
+            closed = true
+            i = end
+        default:
+            if closed {
+                return nil, errors.New("unexpected character in path: " + path)
+            }
+            buf.WriteRune(c)
+        }
+    }
+    if !closed {
+        if buf.Len() == 0 {
+            return nil, errors.New("empty key in path: " + path)
+        }
+        keys = append(keys, buf.String())
+    }
+    return keys, nil
+}
+
+// Query 获取路径匹配的所有值，按文档中的顺序返回
+func (o *Object) Query(path string) ([]*Object, error) {
+    keys, err := ParsePath(path)
+    if err != nil {
+        return nil, err
+    }
+    return o.query(keys), nil
+}
+
+// query 获取与键名列表匹配的所有值
+func (o *Object) query(keys []string) []*Object {
+    matched := []*Object{o}
+    for _, key := range keys {
+        next := make([]*Object, 0)
+        for _, obj := range matched {
+            if obj == nil || obj.Type != TypeMap {
+                continue
+            }
+            m := obj.Value.(*Map)
+            if key == Wildcard {
+                for _, k := range m.Keys {
+                    next = append(next, m.Data[k])
+                }
+                continue
+            }
+            if k := m.GetKey(key); k != nil {
+                next = append(next, m.Data[k])
+            }
+        }
+        matched = next
+    }
+    return matched
+}
+
+// Get 获取路径对应的值，路径必须恰好匹配一个值
+func (o *Object) Get(path string) (*Object, error) {
+    matched, err := o.Query(path)
+    if err != nil {
+        return nil, err
+    }
+    if len(matched) == 0 {
+        return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+    }
+    if len(matched) > 1 {
+        return nil, fmt.Errorf("%s: path matches %d values", path, len(matched))
+    }
+    return matched[0], nil
+}
+
+// Has 判断路径对应的值是否存在
+func (o *Object) Has(path string) bool {
+    matched, err := o.Query(path)
+    return err == nil && len(matched) > 0
+}
+
+// GetString 获取字符串值
+func (o *Object) GetString(path string) (string, error) {
+    v, err := o.getTyped(path, TypeString)
+    if err != nil {
+        return "", err
+    }
+    return util.String(v.Value), nil
+}
+
+// GetInt 获取整数值，支持下划线分隔以及十六进制、八进制、二进制形式
+func (o *Object) GetInt(path string) (int64, error) {
+    v, err := o.getTyped(path, TypeNumber)
+    if err != nil {
+        return 0, err
+    }
+    n, err := strconv.ParseInt(util.NormalizeNumber(util.String(v.Value)), 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("%s: expected integer, got %s", path, util.String(v.Value))
+    }
+    return n, nil
+}
+
+// GetFloat 获取浮点数值，整数也可以作为浮点数读取
+func (o *Object) GetFloat(path string) (float64, error) {
+    v, err := o.getTyped(path, TypeNumber)
+    if err != nil {
+        return 0, err
+    }
+    f, err := strconv.ParseFloat(util.NormalizeNumber(util.String(v.Value)), 64)
+    if err != nil {
+        return 0, fmt.Errorf("%s: expected float, got %s", path, util.String(v.Value))
+    }
+    return f, nil
+}
+
+// GetBool 获取布尔值
+func (o *Object) GetBool(path string) (bool, error) {
+    v, err := o.getTyped(path, TypeBoolean)
+    if err != nil {
+        return false, err
+    }
+    return util.String(v.Value) == "true", nil
+}
+
+// GetDuration 获取时间间隔，值必须是time.ParseDuration支持的字符串，如 "1m30s"
+func (o *Object) GetDuration(path string) (time.Duration, error) {
+    s, err := o.GetString(path)
+    if err != nil {
+        return 0, err
+    }
+    d, err := time.ParseDuration(s)
+    if err != nil {
+        return 0, fmt.Errorf("%s: expected duration, got %q", path, s)
+    }
+    return d, nil
+}
+
+// GetStringSlice 获取字符串数组，数组的每个元素都必须是字符串
+func (o *Object) GetStringSlice(path string) ([]string, error) {
+    v, err := o.Get(path)
+    if err != nil {
+        return nil, err
+    }
+    if v.Type != TypeMap {
+        return nil, fmt.Errorf("%s: expected array, got %s", path, TypeName(v.Type))
+    }
+    m := v.Value.(*Map)
+    if len(m.Keys) > 0 && !m.IsArray() {
+        return nil, fmt.Errorf("%s: expected array, got table", path)
+    }
+    values := make([]string, 0, len(m.Keys))
+    for _, k := range m.Keys {
+        e := m.Data[k]
+        if e.Type != TypeString {
+            return nil, fmt.Errorf("%s[%s]: expected string, got %s", path, k.Value, TypeName(e.Type))
+        }
+        values = append(values, util.String(e.Value))
+    }
+    return values, nil
+}
+
+// getTyped 获取指定类型的值
+func (o *Object) getTyped(path string, t int) (*Object, error) {
+    v, err := o.Get(path)
+    if err != nil {
+        return nil, err
+    }
+    if v.Type != t {
+        return nil, fmt.Errorf("%s: expected %s, got %s", path, TypeName(t), TypeName(v.Type))
+    }
+    return v, nil
+}
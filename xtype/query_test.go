@@ -0,0 +1,101 @@
+package xtype_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/whencome/toml2x/formatter"
+	"github.com/whencome/toml2x/parser"
+	"github.com/whencome/toml2x/xtype"
+)
+
+func parseTable(t *testing.T, toml string) *xtype.Object {
+	toml, err := formatter.Normalize(toml)
+	if err != nil {
+		t.Fatalf("normalize toml failed: %s\n", err)
+	}
+	obj, err := parser.ParseTable(toml)
+	if err != nil {
+		t.Fatalf("parse table failed: %s\n", err)
+	}
+	return obj
+}
+
+func TestQuery(t *testing.T) {
+	obj := parseTable(t, `timeout = "1m30s"
+tags = ["a", "b"]
+[servers.alpha]
+ip = "10.0.0.1"
+port = 8080
+enabled = true
+[servers.beta]
+ip = "10.0.0.2"
+port = 8081
+[[fruits]]
+name = "apple"
+[[fruits]]
+name = "banana"
+[site]
+"google.com" = true`)
+
+	if v, err := obj.GetString("servers.alpha.ip"); err != nil || v != "10.0.0.1" {
+		t.Logf("GetString failed: %v %v\n", v, err)
+		t.Fail()
+	}
+	if v, err := obj.GetInt("servers.beta.port"); err != nil || v != 8081 {
+		t.Logf("GetInt failed: %v %v\n", v, err)
+		t.Fail()
+	}
+	if v, err := obj.GetBool("servers.alpha.enabled"); err != nil || !v {
+		t.Logf("GetBool failed: %v %v\n", v, err)
+		t.Fail()
+	}
+	if v, err := obj.GetDuration("timeout"); err != nil || v != 90*time.Second {
+		t.Logf("GetDuration failed: %v %v\n", v, err)
+		t.Fail()
+	}
+	if v, err := obj.GetStringSlice("tags"); err != nil || len(v) != 2 || v[1] != "b" {
+		t.Logf("GetStringSlice failed: %v %v\n", v, err)
+		t.Fail()
+	}
+	if v, err := obj.GetString("fruits[1].name"); err != nil || v != "banana" {
+		t.Logf("array index failed: %v %v\n", v, err)
+		t.Fail()
+	}
+	if v, err := obj.GetBool(`site."google.com"`); err != nil || !v {
+		t.Logf("quoted key failed: %v %v\n", v, err)
+		t.Fail()
+	}
+
+	ips, err := obj.Query("servers.*.ip")
+	if err != nil || len(ips) != 2 || ips[1].Value != "10.0.0.2" {
+		t.Logf("wildcard query failed: %v %v\n", ips, err)
+		t.Fail()
+	}
+	names, err := obj.Query("fruits[*].name")
+	if err != nil || len(names) != 2 {
+		t.Logf("wildcard index query failed: %v %v\n", names, err)
+		t.Fail()
+	}
+
+	// 错误处理
+	if _, err := obj.GetInt("servers.alpha.ip"); err == nil {
+		t.Log("type mismatch should be reported\n")
+		t.Fail()
+	}
+	if _, err := obj.Get("servers.gamma.ip"); !errors.Is(err, xtype.ErrNotFound) {
+		t.Logf("missing key should return ErrNotFound: %v\n", err)
+		t.Fail()
+	}
+	if _, err := obj.Get("servers.*.ip"); err == nil {
+		t.Log("ambiguous path should be reported\n")
+		t.Fail()
+	}
+	for _, path := range []string{"servers..ip", "fruits[x]", `"open`, "fruits[0]name"} {
+		if _, err := xtype.ParsePath(path); err == nil {
+			t.Logf("invalid path %s should be rejected\n", path)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package xtype
+
+import (
+    "bytes"
+    "sort"
+    "strings"
+
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/util"
+)
+
+// Toml 将对象转换为规范化的toml内容
+// sorted 是否按键名排序，为false时保留原始顺序
+func (o *Object) Toml(sorted bool) string {
+    if o == nil {
+        return ""
+    }
+    if o.Type == TypeMap {
+        m := o.Value.(*Map)
+        if !m.IsArray() {
+            return m.Toml(sorted)
+        }
+    }
+    return o.tomlValue(sorted)
+}
+
+// Toml 将map转换为toml文档
+func (m *Map) Toml(sorted bool) string {
+    buf := bytes.Buffer{}
+    m.writeToml(&buf, nil, sorted)
+    return buf.String()
+}
+
+// writeToml 输出当前表的内容，先输出键值对，再依次输出子表以及表数组
+func (m *Map) writeToml(buf *bytes.Buffer, path []string, sorted bool) {
+    keys := m.tomlKeys(sorted)
+    inlineKeys := make([]*Key, 0)
+    sectionKeys := make([]*Key, 0)
+    width := 0
+    for _, k := range keys {
+        if m.Data[k].isTomlTable() || m.Data[k].isTomlArrayOfTables() {
+            sectionKeys = append(sectionKeys, k)
+            continue
+        }
+        inlineKeys = append(inlineKeys, k)
+        if n := len([]rune(formatter.FmtTomlKey(k.Value))); n > width {
+            width = n
+        }
+    }
+
+    // 键值对，对齐等号
+    for _, k := range inlineKeys {
+        fk := formatter.FmtTomlKey(k.Value)
+        buf.WriteString(fk)
+        buf.WriteString(strings.Repeat(" ", width-len([]rune(fk))))
+        buf.WriteString(" = ")
+        buf.WriteString(m.Data[k].tomlValue(sorted))
+        buf.WriteString("\n")
+    }
+
+    // 子表以及表数组
+    for _, k := range sectionKeys {
+        v := m.Data[k]
+        subPath := make([]string, len(path), len(path)+1)
+        copy(subPath, path)
+        subPath = append(subPath, k.Value)
+        if v.isTomlTable() {
+            sub := v.Value.(*Map)
+            // 仅包含子表的表无需输出表头
+            if sub.hasTomlInlineKeys() {
+                writeTomlSeparator(buf)
+                buf.WriteString("[" + tomlTableName(subPath) + "]\n")
+            }
+            sub.writeToml(buf, subPath, sorted)
+            continue
+        }
+        arr := v.Value.(*Map)
+        for _, ek := range arr.Keys {
+            writeTomlSeparator(buf)
+            buf.WriteString("[[" + tomlTableName(subPath) + "]]\n")
+            arr.Data[ek].Value.(*Map).writeToml(buf, subPath, sorted)
+        }
+    }
+}
+
+// tomlKeys 获取输出的键列表，数组始终保留下标顺序
+func (m *Map) tomlKeys(sorted bool) []*Key {
+    keys := make([]*Key, len(m.Keys))
+    copy(keys, m.Keys)
+    if sorted && !m.IsArray() {
+        sort.SliceStable(keys, func(i, j int) bool {
+            return keys[i].Value < keys[j].Value
+        })
+    }
+    return keys
+}
+
+// hasTomlInlineKeys 判断表中是否存在需要以键值对形式输出的内容
+func (m *Map) hasTomlInlineKeys() bool {
+    if len(m.Keys) == 0 {
+        return true
+    }
+    for _, k := range m.Keys {
+        v := m.Data[k]
+        if !v.isTomlTable() && !v.isTomlArrayOfTables() {
+            return true
+        }
+    }
+    return false
+}
+
+// isTomlTable 判断对象是否以[table]的形式输出
+func (o *Object) isTomlTable() bool {
+    if o == nil || o.Type != TypeMap {
+        return false
+    }
+    m := o.Value.(*Map)
+    return len(m.Keys) > 0 && !m.IsArray()
+}
+
+// isTomlArrayOfTables 判断对象是否以[[table]]的形式输出
+func (o *Object) isTomlArrayOfTables() bool {
+    if o == nil || o.Type != TypeMap {
+        return false
+    }
+    m := o.Value.(*Map)
+    if len(m.Keys) == 0 || !m.IsArray() {
+        return false
+    }
+    for _, k := range m.Keys {
+        v := m.Data[k]
+        if v.Type != TypeMap || v.Value.(*Map).IsArray() {
+            return false
+        }
+    }
+    return true
+}
+
+// TomlValue 将对象转换为toml中的值，表使用行内表的形式
+func (o *Object) TomlValue() string {
+    return o.tomlValue(false)
+}
+
+// tomlValue 将对象转换为toml中的值（行内形式）
+func (o *Object) tomlValue(sorted bool) string {
+    if o == nil {
+        return "\"\""
+    }
+    switch o.Type {
+    case TypeBoolean:
+        return util.String(o.Value)
+    case TypeNumber:
+        return normalizeTomlNumber(util.String(o.Value))
+    case TypeString:
+        return formatter.FmtTomlString(util.String(o.Value))
+    case TypeDatetime:
+        return normalizeTomlDatetime(util.String(o.Value))
+    case TypeMap:
+        m := o.Value.(*Map)
+        buf := bytes.Buffer{}
+        if m.IsArray() {
+            buf.WriteString("[")
+            for i, k := range m.Keys {
+                if i > 0 {
+                    buf.WriteString(", ")
+                }
+                buf.WriteString(m.Data[k].tomlValue(sorted))
+            }
+            buf.WriteString("]")
+            return buf.String()
+        }
+        if len(m.Keys) == 0 {
+            return "{}"
+        }
+        buf.WriteString("{ ")
+        for i, k := range m.tomlKeys(sorted) {
+            if i > 0 {
+                buf.WriteString(", ")
+            }
+            buf.WriteString(formatter.FmtTomlKey(k.Value))
+            buf.WriteString(" = ")
+            buf.WriteString(m.Data[k].tomlValue(sorted))
+        }
+        buf.WriteString(" }")
+        return buf.String()
+    }
+    return "\"\""
+}
+
+// tomlTableName 将表路径转换为表名
+func tomlTableName(path []string) string {
+    names := make([]string, len(path))
+    for i, p := range path {
+        names[i] = formatter.FmtTomlKey(p)
+    }
+    return strings.Join(names, ".")
+}
+
+// writeTomlSeparator 在非空文档的表头之前添加空行
+func writeTomlSeparator(buf *bytes.Buffer) {
+    if buf.Len() > 0 {
+        buf.WriteString("\n")
+    }
+}
+
+// normalizeTomlNumber 规范化数字：去除正号、下划线，十进制数的指数统一使用小写e
+// 0x、0o、0b开头的整数只去除下划线，保留原来的数字
+func normalizeTomlNumber(v string) string {
+    v = strings.TrimPrefix(v, "+")
+    v = strings.ReplaceAll(v, "_", "")
+    if len(v) > 1 && v[0] == '0' && strings.ContainsAny(v[1:2], "xob") {
+        return v
+    }
+    return strings.Replace(v, "E", "e", 1)
+}
+
+// normalizeTomlDatetime 规范化日期时间：日期与时间之间使用T分隔，UTC时区使用Z
+func normalizeTomlDatetime(v string) string {
+    chars := []rune(v)
+    if len(chars) > 10 && (chars[10] == ' ' || chars[10] == 't') {
+        chars[10] = 'T'
+    }
+    if n := len(chars); n > 0 && chars[n-1] == 'z' {
+        chars[n-1] = 'Z'
+    }
+    return string(chars)
+}
@@ -0,0 +1,146 @@
+package xtype
+
+import (
+    "bytes"
+    "strings"
+
+    "github.com/whencome/toml2x/formatter"
+    "github.com/whencome/toml2x/util"
+)
+
+// yamlIndent 每一层缩进的空格数
+const yamlIndent = 2
+
+// Yaml 将对象转换为块格式的YAML文档，保留键的顺序，多行字符串使用字面量块
+func (o *Object) Yaml() string {
+    buf := bytes.Buffer{}
+    if o.isYamlBlock() {
+        o.writeYaml(&buf, 0)
+    } else {
+        buf.WriteString(o.yamlScalar(0))
+        buf.WriteString("\n")
+    }
+    return buf.String()
+}
+
+// isYamlBlock 判断对象是否以多行的块形式输出，空的表和数组使用{}以及行内形式
+func (o *Object) isYamlBlock() bool {
+    if o == nil || o.Type != TypeMap {
+        return false
+    }
+    return len(o.Value.(*Map).Keys) > 0
+}
+
+// writeYaml 以块形式输出表或者数组，每行缩进indent个空格
+func (o *Object) writeYaml(buf *bytes.Buffer, indent int) {
+    m := o.Value.(*Map)
+    prefix := strings.Repeat(" ", indent)
+    if m.IsArray() {
+        for _, k := range m.Keys {
+            v := m.Data[k]
+            if !v.isYamlBlock() {
+                buf.WriteString(prefix + "- " + v.yamlScalar(indent+yamlIndent) + "\n")
+                continue
+            }
+            // 元素的第一行与"- "位于同一行
+            sub := bytes.Buffer{}
+            v.writeYaml(&sub, indent+yamlIndent)
+            buf.WriteString(prefix + "- ")
+            buf.Write(sub.Bytes()[indent+yamlIndent:])
+        }
+        return
+    }
+    for _, k := range m.Keys {
+        v := m.Data[k]
+        buf.WriteString(prefix + formatter.FmtYamlKey(k.Value) + ":")
+        if !v.isYamlBlock() {
+            buf.WriteString(" " + v.yamlScalar(indent+yamlIndent) + "\n")
+            continue
+        }
+        buf.WriteString("\n")
+        // 数组相对于键缩进一层
+        v.writeYaml(buf, indent+yamlIndent)
+    }
+}
+
+// yamlScalar 输出单个值，多行字符串的字面量块内容缩进indent个空格
+func (o *Object) yamlScalar(indent int) string {
+    if o == nil {
+        return "null"
+    }
+    switch o.Type {
+    case TypeBoolean:
+        return util.String(o.Value)
+    case TypeNumber:
+        return yamlNumber(util.String(o.Value))
+    case TypeString:
+        s := util.String(o.Value)
+        if block, ok := yamlLiteral(s, indent); ok {
+            return block
+        }
+        return formatter.FmtYamlString(s)
+    case TypeDatetime:
+        v := normalizeTomlDatetime(util.String(o.Value))
+        // 本地时间会被解析为六十进制的数字，使用字符串
+        if len(v) > 2 && v[2] == ':' {
+            return formatter.FmtYamlString(v)
+        }
+        return v
+    case TypeMap:
+        if o.Value.(*Map).IsArray() {
+            return "[]"
+        }
+        return "{}"
+    }
+    return "null"
+}
+
+// yamlNumber 规范化数字，inf以及nan使用YAML的.inf、.nan
+func yamlNumber(v string) string {
+    n := normalizeTomlNumber(util.NormalizeNumber(v))
+    switch n {
+    case "inf":
+        return ".inf"
+    case "-inf":
+        return "-.inf"
+    case "nan", "-nan":
+        return ".nan"
+    }
+    return n
+}
+
+// yamlLiteral 将多行字符串转换为字面量块，包含无法在字面量块中表示的字符时ok为false
+func yamlLiteral(s string, indent int) (string, bool) {
+    if !strings.Contains(strings.TrimRight(s, "\n"), "\n") {
+        return "", false
+    }
+    for _, c := range s {
+        if (c < 0x20 && c != '\n' && c != '\t') || c == 0x7f || c == 0x85 || c == 0x2028 || c == 0x2029 || c == 0xfeff {
+            return "", false
+        }
+    }
+    buf := bytes.Buffer{}
+    buf.WriteString("|")
+    content := strings.TrimRight(s, "\n")
+    // 第一个非空行以空白开头时需要指定缩进，否则缩进会从该行推断
+    if first := strings.TrimLeft(content, "\n"); first[0] == ' ' || first[0] == '\t' {
+        buf.WriteString("2")
+    }
+    switch trailing := len(s) - len(content); {
+    case trailing == 0:
+        buf.WriteString("-")
+    case trailing > 1:
+        buf.WriteString("+")
+    }
+    prefix := strings.Repeat(" ", indent)
+    for _, line := range strings.Split(content, "\n") {
+        buf.WriteString("\n")
+        if line != "" {
+            buf.WriteString(prefix + line)
+        }
+    }
+    for i := 1; i < len(s)-len(content); i++ {
+        buf.WriteString("\n")
+    }
+    return buf.String(), true
+}